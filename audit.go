@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// defaultAuditUser 是 Kubewarden 默认安装的审计扫描 ServiceAccount 对应的用户名.
+const defaultAuditUser = "system:serviceaccount:kubewarden:audit-scanner"
+
+// isAuditRequest 判断请求是否为后台审计扫描构造的请求.
+// 审计扫描以不带 OldObject 的 CREATE 请求重放集群中已存在的对象，只能按请求发起者识别：
+// 对象中的 metadata.uid 在变更阶段由客户端提供，kubectl create 导出的 YAML 或备份恢复时同样会带上.
+func isAuditRequest(req kubewarden_protocol.KubernetesAdmissionRequest, settings *Settings) bool {
+	if req.Operation != operationCreate || !isEmptyObject(req.OldObject) {
+		return false
+	}
+	_, ok := matchingPattern(req.UserInfo.Username, settings.auditUsers())
+	return ok
+}
+
+// auditUsers 返回审计扫描的用户名模式，未设置 audit_users 时使用 Kubewarden 默认的审计扫描用户.
+func (s *Settings) auditUsers() []string {
+	if s.AuditUsers == nil {
+		return []string{defaultAuditUser}
+	}
+	return s.AuditUsers
+}
+
+// auditDeployment 在审计扫描中把"将被修改"报告为违规，消息中列出缺失或不一致的注解.
+func auditDeployment(d *decision, deployment *appsv1.Deployment, settings Settings) ([]byte, error) {
+	plan, err := planLogAnnotations(deployment, settings)
+	if err != nil {
+		return d.reject(err.Error())
+	}
+	before := snapshotTemplate(deployment)
+	d.recordPlan(plan, before, before.withPlan(plan))
+
+	var problems []string
+	if drift, drifted := describeDrift(plan.annotations, templateAnnotations(deployment)); drifted {
+		problems = append(problems, "pod template annotations are missing or differ: "+drift)
+	}
+	if drift, drifted := describeDrift(plan.labels, templateLabels(deployment)); drifted {
+		problems = append(problems, "pod template labels are missing or differ: "+drift)
+	}
+	if len(problems) == 0 {
+		return d.accept("audited deployment is up to date")
+	}
+	return d.reject("deployment would be mutated, " + strings.Join(problems, "; "))
+}
+
+// isEmptyObject 判断请求中的对象字段是否为空.
+func isEmptyObject(object json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(object)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestBackgroundAudit(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+	}
+	newDeployment := func(uid string, annotations map[string]string) appsv1.Deployment {
+		deployment := newTestDeployment("app", envContainer("my-container", "vestack_varlog", "/var/log/app.log"))
+		deployment.Metadata.UID = uid
+		deployment.Spec.Template.Metadata.Annotations = annotations
+		return deployment
+	}
+	const uid = "6b0e2c1d-7a4f-4c1e-9f0a-2d8e5b3c1a77"
+
+	tests := []struct {
+		name            string
+		settings        Settings
+		username        string
+		deployment      appsv1.Deployment
+		shouldMutate    bool
+		expectedMessage string
+	}{
+		{
+			name:         "admission CREATE is mutated",
+			settings:     settings,
+			username:     "kubernetes-admin",
+			deployment:   newDeployment("", nil),
+			shouldMutate: true,
+		},
+		{
+			// kubectl create -f 导出的 YAML 或备份恢复时对象已带有 uid
+			name:         "admission CREATE carrying a uid is mutated",
+			settings:     settings,
+			username:     "kubernetes-admin",
+			deployment:   newDeployment(uid, nil),
+			shouldMutate: true,
+		},
+		{
+			name:       "audited deployment without annotations is reported",
+			settings:   settings,
+			username:   defaultAuditUser,
+			deployment: newDeployment(uid, nil),
+			expectedMessage: "deployment would be mutated, pod template annotations are missing or differ: " +
+				`co_elastic_logs_path="/var/log/app.log" (missing)`,
+		},
+		{
+			name:     "audited deployment with annotations is accepted",
+			settings: settings,
+			username: defaultAuditUser,
+			deployment: newDeployment(uid, map[string]string{
+				"co_elastic_logs_path": "/var/log/app.log",
+			}),
+		},
+		{
+			name:     "audited deployment without spec is accepted",
+			settings: settings,
+			username: defaultAuditUser,
+			deployment: appsv1.Deployment{
+				Metadata: &metav1.ObjectMeta{Name: "app", UID: uid},
+			},
+		},
+		{
+			name: "audit_users replaces the default audit user",
+			settings: func() Settings {
+				custom := settings
+				custom.AuditUsers = []string{"system:serviceaccount:*:audit-scanner"}
+				return custom
+			}(),
+			username:   "system:serviceaccount:security:audit-scanner",
+			deployment: newDeployment(uid, nil),
+			expectedMessage: "deployment would be mutated, pod template annotations are missing or differ: " +
+				`co_elastic_logs_path="/var/log/app.log" (missing)`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:      kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Operation: "CREATE",
+					UserInfo:  kubewarden_protocol.UserInfo{Username: test.username},
+					Object:    json.RawMessage(mustMarshalJSON(test.deployment)),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(test.settings)),
+			}
+
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			switch {
+			case test.shouldMutate:
+				assertMutation(t, response, map[string]string{"co_elastic_logs_path": "/var/log/app.log"})
+			case test.expectedMessage != "":
+				if response.Accepted {
+					t.Fatalf("Expected request to be rejected")
+				}
+				if *response.Message != test.expectedMessage {
+					t.Errorf("Expected message %q, got %q", test.expectedMessage, *response.Message)
+				}
+			default:
+				assertNoMutation(t, response)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+const (
+	defaultImageRegistry = "docker.io"
+	defaultImageTag      = "latest"
+	// officialImagePrefix 是 Docker Hub 官方镜像省略的仓库前缀.
+	officialImagePrefix = "library/"
+)
+
+// ContainerSelection 定义了读取 env_key 的容器，未设置时只读取第一个容器.
+type ContainerSelection struct {
+	// Names 容器名称，支持 glob 模式
+	Names []string `json:"names,omitempty"`
+	// Images 镜像引用模式，例如 registry.example.com/java/*:8-*
+	Images []string `json:"images,omitempty"`
+}
+
+// Valid 校验容器名称和镜像模式.
+func (c *ContainerSelection) Valid() error {
+	var errs FieldErrors
+	validGlobPatterns(&errs, "containers.names", c.Names)
+	for i, pattern := range c.Images {
+		if _, err := parseImagePattern(pattern); err != nil {
+			errs.add(fieldIndex("containers.images", i), "%q is not a valid image pattern: %v", pattern, err)
+		}
+	}
+	return errs.err()
+}
+
+// selectContainers 返回需要读取 env_key 的容器.
+// 未配置 names 和 images 时只返回第一个容器；同时配置时容器必须同时满足两者.
+func (c *ContainerSelection) selectContainers(deployment *appsv1.Deployment) []*corev1.Container {
+	if deployment.Spec == nil || deployment.Spec.Template == nil || deployment.Spec.Template.Spec == nil {
+		return nil
+	}
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(c.Names) == 0 && len(c.Images) == 0 {
+		if len(containers) == 0 || containers[0] == nil {
+			return nil
+		}
+		return containers[:1]
+	}
+
+	var selected []*corev1.Container
+	for _, container := range containers {
+		if container != nil && c.matches(container) {
+			selected = append(selected, container)
+		}
+	}
+	return selected
+}
+
+func (c *ContainerSelection) matches(container *corev1.Container) bool {
+	if len(c.Names) > 0 {
+		if container.Name == nil {
+			return false
+		}
+		if _, ok := matchingPattern(*container.Name, c.Names); !ok {
+			return false
+		}
+	}
+	if len(c.Images) == 0 {
+		return true
+	}
+	image, err := parseImageReference(container.Image)
+	if err != nil {
+		return false
+	}
+	for _, raw := range c.Images {
+		if pattern, err := parseImagePattern(raw); err == nil && pattern.matches(image) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageReference 是按 Docker 规则规范化后的镜像引用.
+type imageReference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageReference 解析镜像引用，未写仓库地址时为 docker.io，
+// Docker Hub 的单段仓库名补全为 library/<name>，既没有 tag 也没有 digest 时 tag 为 latest.
+func parseImageReference(image string) (imageReference, error) {
+	ref, err := splitImageReference(image)
+	if err != nil {
+		return ref, err
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = defaultImageTag
+	}
+	return ref, nil
+}
+
+// parseImagePattern 与 parseImageReference 相同，但保留未写的 tag 和 digest，表示匹配任意取值.
+// 首段为 "*" 时同样视为仓库地址，用于匹配任意仓库.
+func parseImagePattern(pattern string) (imageReference, error) {
+	ref, err := splitImageReference(pattern)
+	if err != nil {
+		return ref, err
+	}
+	for _, part := range []string{ref.registry, ref.repository, ref.tag, ref.digest} {
+		if _, err = path.Match(part, ""); err != nil {
+			return ref, err
+		}
+	}
+	return ref, nil
+}
+
+func splitImageReference(image string) (imageReference, error) {
+	var ref imageReference
+	if image == "" {
+		return ref, errors.New("image cannot be empty")
+	}
+
+	remainder := image
+	if at := strings.Index(remainder, "@"); at >= 0 {
+		ref.digest = remainder[at+1:]
+		remainder = remainder[:at]
+		if ref.digest == "" {
+			return ref, errors.New("digest cannot be empty")
+		}
+	}
+	// tag 只能出现在最后一个 "/" 之后，避免把 host:port 误认为 tag
+	if colon := strings.LastIndex(remainder, ":"); colon > strings.LastIndex(remainder, "/") {
+		ref.tag = remainder[colon+1:]
+		remainder = remainder[:colon]
+		if ref.tag == "" {
+			return ref, errors.New("tag cannot be empty")
+		}
+	}
+
+	ref.registry = defaultImageRegistry
+	if slash := strings.Index(remainder, "/"); slash >= 0 && isRegistryComponent(remainder[:slash]) {
+		ref.registry = remainder[:slash]
+		remainder = remainder[slash+1:]
+	}
+	if remainder == "" {
+		return ref, errors.New("repository cannot be empty")
+	}
+	if ref.registry == defaultImageRegistry && !strings.Contains(remainder, "/") {
+		remainder = officialImagePrefix + remainder
+	}
+	ref.repository = remainder
+	return ref, nil
+}
+
+// isRegistryComponent 判断镜像引用的首段是否为仓库地址.
+func isRegistryComponent(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost" || component == "*"
+}
+
+// matches 判断镜像是否满足模式，模式中未写的 tag 或 digest 匹配任意取值.
+func (p imageReference) matches(image imageReference) bool {
+	return globMatch(p.registry, image.registry) &&
+		globMatch(p.repository, image.repository) &&
+		(p.tag == "" || globMatch(p.tag, image.tag)) &&
+		(p.digest == "" || globMatch(p.digest, image.digest))
+}
+
+func globMatch(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
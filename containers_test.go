@@ -0,0 +1,207 @@
+package main
+
+import (
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		expected imageReference
+	}{
+		{
+			name:     "official image without registry or tag",
+			image:    "nginx",
+			expected: imageReference{registry: "docker.io", repository: "library/nginx", tag: "latest"},
+		},
+		{
+			name:     "docker hub image with namespace",
+			image:    "bitnami/redis:7.2",
+			expected: imageReference{registry: "docker.io", repository: "bitnami/redis", tag: "7.2"},
+		},
+		{
+			name:     "registry with port",
+			image:    "localhost:5000/team/app:1.0",
+			expected: imageReference{registry: "localhost:5000", repository: "team/app", tag: "1.0"},
+		},
+		{
+			name:     "localhost registry without port",
+			image:    "localhost/app",
+			expected: imageReference{registry: "localhost", repository: "app", tag: "latest"},
+		},
+		{
+			name:     "digest without tag",
+			image:    "registry.example.com/app@sha256:abc",
+			expected: imageReference{registry: "registry.example.com", repository: "app", digest: "sha256:abc"},
+		},
+		{
+			name:  "tag and digest",
+			image: "registry.example.com/app:1.0@sha256:abc",
+			expected: imageReference{
+				registry: "registry.example.com", repository: "app", tag: "1.0", digest: "sha256:abc",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ref, err := parseImageReference(test.image)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ref != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, ref)
+			}
+		})
+	}
+}
+
+func TestImagePatternMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		image    string
+		expected bool
+	}{
+		{name: "short name matches official image", pattern: "nginx", image: "docker.io/library/nginx:1.25",
+			expected: true},
+		{name: "pattern without tag matches any tag", pattern: "nginx", image: "nginx:1.25", expected: true},
+		{name: "tag glob", pattern: "registry.example.com/java/*:17-*", image: "registry.example.com/java/api:17-jre",
+			expected: true},
+		{name: "tag glob mismatch", pattern: "registry.example.com/java/*:17-*",
+			image: "registry.example.com/java/api:21", expected: false},
+		{name: "registry must match", pattern: "registry.example.com/app", image: "docker.io/app", expected: false},
+		{name: "any registry", pattern: "*/team/app", image: "localhost:5000/team/app:1.0", expected: true},
+		{name: "digest", pattern: "app@sha256:abc", image: "app:1.0@sha256:abc", expected: true},
+		{name: "digest mismatch", pattern: "app@sha256:abc", image: "app@sha256:def", expected: false},
+		{name: "tag pattern against digest-only image", pattern: "app:1.0", image: "app@sha256:abc", expected: false},
+		{name: "glob does not cross path segments", pattern: "registry.example.com/*",
+			image: "registry.example.com/team/app", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pattern, err := parseImagePattern(test.pattern)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			image, err := parseImageReference(test.image)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if matched := pattern.matches(image); matched != test.expected {
+				t.Errorf("Expected %q to match %q: %v, got %v", test.pattern, test.image, test.expected, matched)
+			}
+		})
+	}
+}
+
+func TestContainerSelectionValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		selection     ContainerSelection
+		expectedError string
+	}{
+		{name: "empty", selection: ContainerSelection{}},
+		{name: "valid", selection: ContainerSelection{Names: []string{"app-*"}, Images: []string{"*/team/*:1.*"}}},
+		{
+			name:          "empty name",
+			selection:     ContainerSelection{Names: []string{""}},
+			expectedError: "containers.names[0]: cannot be empty",
+		},
+		{
+			name:          "malformed name glob",
+			selection:     ContainerSelection{Names: []string{"app-["}},
+			expectedError: `containers.names[0]: "app-[" is not a valid glob pattern: syntax error in pattern`,
+		},
+		{
+			name:          "empty tag",
+			selection:     ContainerSelection{Images: []string{"nginx:"}},
+			expectedError: `containers.images[0]: "nginx:" is not a valid image pattern: tag cannot be empty`,
+		},
+		{
+			name:          "empty digest",
+			selection:     ContainerSelection{Images: []string{"nginx", "nginx@"}},
+			expectedError: `containers.images[1]: "nginx@" is not a valid image pattern: digest cannot be empty`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.selection.Valid()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestSelectContainers(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{Name: stringPtr("istio-proxy"), Image: "docker.io/istio/proxyv2:1.21"},
+						{Name: stringPtr("app"), Image: "registry.example.com/team/app:2.0"},
+						{Name: stringPtr("app-worker"), Image: "registry.example.com/team/worker:2.0"},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		selection ContainerSelection
+		expected  []string
+	}{
+		{name: "first container by default", selection: ContainerSelection{}, expected: []string{"istio-proxy"}},
+		{
+			name:      "by name",
+			selection: ContainerSelection{Names: []string{"app*"}},
+			expected:  []string{"app", "app-worker"},
+		},
+		{
+			name:      "by image",
+			selection: ContainerSelection{Images: []string{"registry.example.com/team/*"}},
+			expected:  []string{"app", "app-worker"},
+		},
+		{
+			name: "by name and image",
+			selection: ContainerSelection{
+				Names:  []string{"app*"},
+				Images: []string{"registry.example.com/team/app"},
+			},
+			expected: []string{"app"},
+		},
+		{name: "no match", selection: ContainerSelection{Names: []string{"db"}}, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, container := range test.selection.selectContainers(deployment) {
+				names = append(names, *container.Name)
+			}
+			if len(names) != len(test.expected) {
+				t.Fatalf("Expected containers %v, got %v", test.expected, names)
+			}
+			for i := range names {
+				if names[i] != test.expected[i] {
+					t.Errorf("Expected containers %v, got %v", test.expected, names)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const (
+	datadogAnnotationFormat     = "ad.datadoghq.com/%s.logs"
+	datadogLogTypeFile          = "file"
+	defaultDatadogMaxValueBytes = 65536
+)
+
+// DatadogOptions 定义了 datadog profile 的选项.
+type DatadogOptions struct {
+	// Source 日志来源，默认使用容器名称
+	Source string `json:"source,omitempty"`
+	// Service 服务名称，默认使用容器名称
+	Service string `json:"service,omitempty"`
+	// LogProcessingRules 附加到每个日志文件配置上的处理规则
+	LogProcessingRules []DatadogLogProcessingRule `json:"log_processing_rules,omitempty"`
+	// MaxValueBytes 渲染后的 JSON 注解值的最大字节数，默认 65536
+	MaxValueBytes int `json:"max_value_bytes,omitempty"`
+}
+
+// DatadogLogProcessingRule 对应 Datadog 的 log_processing_rules 条目.
+type DatadogLogProcessingRule struct {
+	Type               string `json:"type"`
+	Name               string `json:"name"`
+	Pattern            string `json:"pattern"`
+	ReplacePlaceholder string `json:"replace_placeholder,omitempty"`
+}
+
+// datadogLogConfig 是 ad.datadoghq.com/<container>.logs 注解中的一个日志配置.
+type datadogLogConfig struct {
+	Type               string                     `json:"type"`
+	Path               string                     `json:"path"`
+	Source             string                     `json:"source"`
+	Service            string                     `json:"service"`
+	LogProcessingRules []DatadogLogProcessingRule `json:"log_processing_rules,omitempty"`
+}
+
+// Valid 校验 datadog 选项.
+func (o *DatadogOptions) Valid() error {
+	var errs FieldErrors
+	if o.MaxValueBytes < 0 {
+		errs.add("profile_options.max_value_bytes", "cannot be negative")
+	}
+	for i, rule := range o.LogProcessingRules {
+		field := fieldIndex("profile_options.log_processing_rules", i)
+		switch rule.Type {
+		case "exclude_at_match", "include_at_match", "multi_line":
+			if rule.ReplacePlaceholder != "" {
+				errs.add(field+".replace_placeholder", "is only supported by mask_sequences")
+			}
+		case "mask_sequences":
+			if rule.ReplacePlaceholder == "" {
+				errs.add(field+".replace_placeholder", "cannot be empty")
+			}
+		default:
+			errs.add(field+".type", "must be exclude_at_match, include_at_match, mask_sequences or multi_line, "+
+				"got %q", rule.Type)
+		}
+		if rule.Name == "" {
+			errs.add(field+".name", "cannot be empty")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			errs.add(field+".pattern", "must be a valid regular expression")
+		}
+	}
+	return errs.err()
+}
+
+func (o *DatadogOptions) maxValueBytes() int {
+	if o.MaxValueBytes == 0 {
+		return defaultDatadogMaxValueBytes
+	}
+	return o.MaxValueBytes
+}
+
+// Render 为每个容器生成 ad.datadoghq.com/<container>.logs 注解.
+func (o *DatadogOptions) Render(result *DiscoveryResult) (map[string]string, error) {
+	return renderContainers(result, o.render)
+}
+
+// render 把容器的日志路径渲染为 ad.datadoghq.com/<container>.logs 注解.
+func (o *DatadogOptions) render(containerName string, logPaths []string) (map[string]string, error) {
+	key := fmt.Sprintf(datadogAnnotationFormat, containerName)
+	if err := validateQualifiedName(key); err != nil {
+		return nil, fmt.Errorf("container %q cannot be used in the datadog annotation key %q: %w",
+			containerName, key, err)
+	}
+
+	configs := make([]datadogLogConfig, 0, len(logPaths))
+	for _, logPath := range logPaths {
+		configs = append(configs, datadogLogConfig{
+			Type:               datadogLogTypeFile,
+			Path:               logPath,
+			Source:             defaultIfEmpty(o.Source, containerName),
+			Service:            defaultIfEmpty(o.Service, containerName),
+			LogProcessingRules: o.LogProcessingRules,
+		})
+	}
+	value, err := json.Marshal(configs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot render datadog annotation: %w", err)
+	}
+	if len(value) > o.maxValueBytes() {
+		return nil, fmt.Errorf("datadog annotation %q would be %d bytes, more than max_value_bytes %d",
+			key, len(value), o.maxValueBytes())
+	}
+	return map[string]string{key: string(value)}, nil
+}
+
+func defaultIfEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
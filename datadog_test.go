@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDatadogOptionsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		expectedError string
+	}{
+		{name: "no options", options: `{}`},
+		{
+			name: "complete options",
+			options: `{
+				"source": "java",
+				"service": "payments",
+				"log_processing_rules": [
+					{"type": "multi_line", "name": "stack_traces", "pattern": "\\d{4}-\\d{2}-\\d{2}"},
+					{"type": "mask_sequences", "name": "mask_tokens", "pattern": "token=\\w+",
+					 "replace_placeholder": "token=[masked]"}
+				]
+			}`,
+		},
+		{
+			name:          "unknown field",
+			options:       `{"sources": "java"}`,
+			expectedError: `profile_options: json: unknown field "sources"`,
+		},
+		{
+			name:          "negative max value bytes",
+			options:       `{"max_value_bytes": -1}`,
+			expectedError: "profile_options.max_value_bytes: cannot be negative",
+		},
+		{
+			name:    "unknown rule type",
+			options: `{"log_processing_rules": [{"type": "drop", "name": "x", "pattern": "x"}]}`,
+			expectedError: "profile_options.log_processing_rules[0].type: must be exclude_at_match, " +
+				`include_at_match, mask_sequences or multi_line, got "drop"`,
+		},
+		{
+			name:          "rule without name",
+			options:       `{"log_processing_rules": [{"type": "exclude_at_match", "pattern": "x"}]}`,
+			expectedError: "profile_options.log_processing_rules[0].name: cannot be empty",
+		},
+		{
+			name:          "invalid rule pattern",
+			options:       `{"log_processing_rules": [{"type": "exclude_at_match", "name": "x", "pattern": "("}]}`,
+			expectedError: "profile_options.log_processing_rules[0].pattern: must be a valid regular expression",
+		},
+		{
+			name:          "mask without placeholder",
+			options:       `{"log_processing_rules": [{"type": "mask_sequences", "name": "x", "pattern": "x"}]}`,
+			expectedError: "profile_options.log_processing_rules[0].replace_placeholder: cannot be empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileDatadog, ProfileOptions: json.RawMessage(test.options)}
+			err := settings.validProfile()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestDatadogRender(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		containerName string
+		expected      map[string]string
+		expectedError string
+	}{
+		{
+			name:          "defaults to the container name",
+			options:       `{}`,
+			containerName: "app",
+			expected: map[string]string{
+				"ad.datadoghq.com/app.logs": `[{"type":"file","path":"/var/log/app.log",` +
+					`"source":"app","service":"app"},{"type":"file","path":"/var/log/error.log",` +
+					`"source":"app","service":"app"}]`,
+			},
+		},
+		{
+			name: "source, service and rules",
+			options: `{"source": "java", "service": "payments",
+				"log_processing_rules": [{"type": "exclude_at_match", "name": "health", "pattern": "GET /health"}]}`,
+			containerName: "app",
+			expected: map[string]string{
+				"ad.datadoghq.com/app.logs": `[{"type":"file","path":"/var/log/app.log","source":"java",` +
+					`"service":"payments","log_processing_rules":[{"type":"exclude_at_match","name":"health",` +
+					`"pattern":"GET /health"}]},{"type":"file","path":"/var/log/error.log","source":"java",` +
+					`"service":"payments","log_processing_rules":[{"type":"exclude_at_match","name":"health",` +
+					`"pattern":"GET /health"}]}]`,
+			},
+		},
+		{
+			name:          "value larger than max value bytes",
+			options:       `{"max_value_bytes": 100}`,
+			containerName: "app",
+			expectedError: `datadog annotation "ad.datadoghq.com/app.logs" would be 149 bytes, ` +
+				"more than max_value_bytes 100",
+		},
+		{
+			name:          "container name too long for the key",
+			options:       `{}`,
+			containerName: strings.Repeat("a", 60),
+			expectedError: `container "` + strings.Repeat("a", 60) + `" cannot be used in the datadog annotation key ` +
+				`"ad.datadoghq.com/` + strings.Repeat("a", 60) + `.logs": ` +
+				"name part must be no more than 63 characters",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileDatadog, ProfileOptions: json.RawMessage(test.options)}
+			annotations, err := renderContainer(settings, test.containerName,
+				[]string{"/var/log/app.log", "/var/log/error.log"})
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(annotations, test.expected) {
+				t.Errorf("Expected annotations %v, got %v", test.expected, annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"sort"
+
+	onelog "github.com/francoispqt/onelog"
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	// LogLevelDebug 额外输出被跳过的请求.
+	LogLevelDebug = "debug"
+	// LogLevelInfo 输出放行、修改和拒绝的决定，为默认级别.
+	LogLevelInfo = "info"
+	// LogLevelWarn 只输出拒绝的决定.
+	LogLevelWarn = "warn"
+	// LogLevelError 不输出准入决定.
+	LogLevelError = "error"
+)
+
+// validLogLevel 校验 log_level.
+func (s *Settings) validLogLevel() error {
+	var errs FieldErrors
+	switch s.LogLevel {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		errs.add("log_level", "must be %s, %s, %s or %s, got %q",
+			LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError, s.LogLevel)
+	}
+	return errs.err()
+}
+
+// logLevels 返回 log_level 对应的 onelog 级别掩码.
+func (s *Settings) logLevels() uint8 {
+	switch s.LogLevel {
+	case LogLevelDebug:
+		return onelog.ALL
+	case LogLevelWarn:
+		return onelog.WARN | onelog.ERROR | onelog.FATAL
+	case LogLevelError:
+		return onelog.ERROR | onelog.FATAL
+	default:
+		return onelog.INFO | onelog.WARN | onelog.ERROR | onelog.FATAL
+	}
+}
+
+// newLogger 返回按 log_level 过滤的日志记录器，每个请求使用各自策略实例的配置.
+func (s *Settings) newLogger() *onelog.Logger {
+	return onelog.New(&logWriter, s.logLevels())
+}
+
+// decision 收集一次准入决定的上下文，在返回响应时写出一条结构化日志.
+type decision struct {
+	log *onelog.Logger
+	req *kubewarden_protocol.KubernetesAdmissionRequest
+	// name 工作负载名称，反序列化对象后以对象中的名称为准
+	name string
+	// planned 是否已经计算出注解，未计算时日志中不输出容器、路径和键
+	planned     bool
+	containers  logStrings
+	logPaths    logStrings
+	annotations keyChanges
+	labels      keyChanges
+}
+
+// keyChanges 是注解或标签按键分类的变化.
+type keyChanges struct {
+	written   logStrings
+	removed   logStrings
+	unchanged logStrings
+}
+
+func newDecision(log *onelog.Logger, req *kubewarden_protocol.KubernetesAdmissionRequest) *decision {
+	return &decision{log: log, req: req, name: req.Name}
+}
+
+// observe 记录工作负载的名称，CREATE 请求中只有 generateName 时使用它.
+func (d *decision) observe(deployment *appsv1.Deployment) {
+	if deployment.Metadata == nil {
+		return
+	}
+	switch {
+	case deployment.Metadata.Name != "":
+		d.name = deployment.Metadata.Name
+	case d.name == "":
+		d.name = deployment.Metadata.GenerateName
+	}
+}
+
+// recordPlan 记录被选中的容器、日志路径，以及 Pod 模板的注解和标签从 before 到 after 的变化.
+func (d *decision) recordPlan(plan logPlan, before, after templateMeta) {
+	d.planned = true
+	d.containers = nil
+	for _, entry := range plan.containers {
+		if entry.container.Name != nil {
+			d.containers = append(d.containers, *entry.container.Name)
+		}
+	}
+	d.logPaths = plan.logPaths
+	d.annotations = diffKeys(before.annotations, after.annotations, plan.annotations)
+	d.labels = diffKeys(before.labels, after.labels, plan.labels)
+}
+
+// skip 放行与策略无关的请求，只在 debug 级别记录.
+func (d *decision) skip(reason string) ([]byte, error) {
+	d.write(d.log.DebugWith("admission request skipped"), "skip", reason)
+	return kubewarden.AcceptRequest()
+}
+
+// accept 放行请求且不做修改.
+func (d *decision) accept(reason string) ([]byte, error) {
+	d.write(d.log.InfoWith("admission request accepted"), "accept", reason)
+	return kubewarden.AcceptRequest()
+}
+
+// mutate 放行请求并返回修改后的 Deployment.
+func (d *decision) mutate(deployment *appsv1.Deployment, reason string) ([]byte, error) {
+	d.write(d.log.InfoWith("admission request mutated"), "mutate", reason)
+	return kubewarden.MutateRequest(deployment)
+}
+
+// reject 拒绝请求，reason 同时作为返回给 API Server 的消息.
+func (d *decision) reject(reason string) ([]byte, error) {
+	d.write(d.log.WarnWith("admission request rejected"), "reject", reason)
+	return kubewarden.RejectRequest(kubewarden.Message(reason), kubewarden.Code(RejectCode))
+}
+
+func (d *decision) write(entry onelog.ChainEntry, outcome, reason string) {
+	entry = entry.String("uid", d.req.Uid).
+		String("kind", d.req.Kind.Kind).
+		String("namespace", d.req.Namespace).
+		String("name", d.name).
+		String("operation", d.req.Operation).
+		String("decision", outcome).
+		String("reason", reason)
+	if d.planned {
+		entry = entry.Array("containers", d.containers).
+			Array("log_paths", d.logPaths).
+			Array("annotations_written", d.annotations.written).
+			Array("annotations_removed", d.annotations.removed).
+			Array("annotations_unchanged", d.annotations.unchanged).
+			Array("labels_written", d.labels.written).
+			Array("labels_removed", d.labels.removed).
+			Array("labels_unchanged", d.labels.unchanged)
+	}
+	entry.Write()
+}
+
+// templateMeta 是 Pod 模板注解和标签的快照.
+type templateMeta struct {
+	annotations map[string]string
+	labels      map[string]string
+}
+
+// snapshotTemplate 复制 Pod 模板当前的注解和标签，之后的修改不会影响快照.
+func snapshotTemplate(deployment *appsv1.Deployment) templateMeta {
+	return templateMeta{
+		annotations: copyStrings(templateAnnotations(deployment)),
+		labels:      copyStrings(templateLabels(deployment)),
+	}
+}
+
+// withPlan 返回把 plan 写入快照后的结果，用于描述不修改对象时本会产生的变化.
+func (m templateMeta) withPlan(plan logPlan) templateMeta {
+	merged := templateMeta{annotations: copyStrings(m.annotations), labels: copyStrings(m.labels)}
+	for key, value := range plan.annotations {
+		merged.annotations[key] = value
+	}
+	for key, value := range plan.labels {
+		merged.labels[key] = value
+	}
+	return merged
+}
+
+// diffKeys 比较 before 和 after：新增或取值改变的键为 written，消失的键为 removed，
+// planned 中取值与 before 相同的键为 unchanged.
+func diffKeys(before, after, planned map[string]string) keyChanges {
+	changes := keyChanges{written: logStrings{}, removed: logStrings{}, unchanged: logStrings{}}
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || previous != value {
+			changes.written = append(changes.written, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes.removed = append(changes.removed, key)
+		}
+	}
+	for key, value := range planned {
+		if previous, ok := before[key]; ok && previous == value {
+			changes.unchanged = append(changes.unchanged, key)
+		}
+	}
+	sort.Strings(changes.written)
+	sort.Strings(changes.removed)
+	sort.Strings(changes.unchanged)
+	return changes
+}
+
+func copyStrings(values map[string]string) map[string]string {
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return copied
+}
+
+// logStrings 以 JSON 数组的形式写入日志.
+type logStrings []string
+
+// MarshalJSONArray 实现 gojay.MarshalerJSONArray.
+func (l logStrings) MarshalJSONArray(enc *onelog.Encoder) {
+	for _, value := range l {
+		enc.String(value)
+	}
+}
+
+// IsNil 实现 gojay.MarshalerJSONArray，空数组仍然输出为 [].
+func (l logStrings) IsNil() bool {
+	return false
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	onelog "github.com/francoispqt/onelog"
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidLogLevel(t *testing.T) {
+	for _, level := range []string{"", "debug", "info", "warn", "error"} {
+		settings := Settings{LogLevel: level}
+		if err := settings.validLogLevel(); err != nil {
+			t.Errorf("Unexpected error for %q: %v", level, err)
+		}
+	}
+
+	settings := Settings{LogLevel: "trace"}
+	expected := `log_level: must be debug, info, warn or error, got "trace"`
+	if err := settings.validLogLevel(); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got: %v", expected, err)
+	}
+}
+
+func TestDecisionLogLevels(t *testing.T) {
+	tests := []struct {
+		level    string
+		expected []string
+	}{
+		{level: "debug", expected: []string{"skip", "accept", "reject"}},
+		{level: "", expected: []string{"accept", "reject"}},
+		{level: "warn", expected: []string{"reject"}},
+		{level: "error", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			settings := Settings{LogLevel: test.level}
+			var buffer bytes.Buffer
+			req := &kubewarden_protocol.KubernetesAdmissionRequest{}
+			d := newDecision(onelog.New(&buffer, settings.logLevels()), req)
+			_, _ = d.skip("skipped")
+			_, _ = d.accept("accepted")
+			_, _ = d.reject("rejected")
+
+			var outcomes []string
+			for _, entry := range decodeLogEntries(t, &buffer) {
+				outcomes = append(outcomes, entry["decision"].(string))
+			}
+			if !reflect.DeepEqual(outcomes, test.expected) {
+				t.Errorf("Expected decisions %v to be logged, got %v", test.expected, outcomes)
+			}
+		})
+	}
+}
+
+func TestMutateDeploymentLogsDecision(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		LogPathsLabel:       "logs.example.com/enabled",
+	}
+	deployment := appsv1.Deployment{
+		Metadata: &metav1.ObjectMeta{Name: "app"},
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Metadata: &metav1.ObjectMeta{
+					Annotations: map[string]string{"co_elastic_logs_path": "/var/log/app.log"},
+				},
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("my-container"),
+							Env: []*corev1.EnvVar{
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"},
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/error.log"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	req := &kubewarden_protocol.KubernetesAdmissionRequest{
+		Uid:       "0c8b6a52-6f2e-4f4e-9a55-1f1a4c2b7d10",
+		Kind:      kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+		Namespace: "payments",
+		Operation: "UPDATE",
+	}
+
+	var buffer bytes.Buffer
+	d := newDecision(onelog.New(&buffer, onelog.ALL), req)
+	d.observe(&deployment)
+	if _, err := mutateDeployment(d, &deployment, settings, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries := decodeLogEntries(t, &buffer)
+	if len(entries) != 1 {
+		t.Fatalf("Expected one log entry, got %d", len(entries))
+	}
+	expected := map[string]interface{}{
+		"level":                 "info",
+		"message":               "admission request mutated",
+		"uid":                   "0c8b6a52-6f2e-4f4e-9a55-1f1a4c2b7d10",
+		"kind":                  "Deployment",
+		"namespace":             "payments",
+		"name":                  "app",
+		"operation":             "UPDATE",
+		"decision":              "mutate",
+		"reason":                "pod template updated",
+		"containers":            []interface{}{"my-container"},
+		"log_paths":             []interface{}{"/var/log/app.log", "/var/log/error.log"},
+		"annotations_written":   []interface{}{"co_elastic_logs_path_ext_1"},
+		"annotations_removed":   []interface{}{},
+		"annotations_unchanged": []interface{}{"co_elastic_logs_path"},
+		"labels_written":        []interface{}{"logs.example.com/enabled"},
+		"labels_removed":        []interface{}{},
+		"labels_unchanged":      []interface{}{},
+	}
+	if !reflect.DeepEqual(entries[0], expected) {
+		t.Errorf("Expected log entry %v, got %v", expected, entries[0])
+	}
+}
+
+func TestDiffKeys(t *testing.T) {
+	before := map[string]string{"same": "1", "changed": "1", "gone": "1", "other": "1"}
+	after := map[string]string{"same": "1", "changed": "2", "added": "1", "other": "1"}
+	planned := map[string]string{"same": "1", "changed": "2", "added": "1"}
+
+	changes := diffKeys(before, after, planned)
+	expected := keyChanges{
+		written:   logStrings{"added", "changed"},
+		removed:   logStrings{"gone"},
+		unchanged: logStrings{"same"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+}
+
+func decodeLogEntries(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	decoder := json.NewDecoder(buffer)
+	for decoder.More() {
+		var entry map[string]interface{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("Cannot decode log entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -51,7 +51,7 @@
     "annotated-policy.wasm"
 
   [ "$status" -ne 0 ]
-  [[ "$output" == *'additional_annotations keys cannot be empty'* ]]
+  [[ "$output" == *'additional_annotations['*']: key cannot be empty'* ]]
   [[ "$output" != *'"allowed":true'* ]]
 }
 
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	elasticHintsPrefix = "co.elastic.logs"
+	// sampleContainerName 用于在加载配置时渲染样例注解键.
+	sampleContainerName = "container"
+)
+
+// ElasticHintsOptions 定义了 elastic-hints profile 的选项.
+type ElasticHintsOptions struct {
+	// PerContainer 是否输出 co.elastic.logs.<container>/* 形式的容器级 hints
+	PerContainer bool `json:"per_container,omitempty"`
+	// Enabled 是否采集，默认 true；为 false 时只输出 enabled 注解
+	Enabled *bool `json:"enabled,omitempty"`
+	// Multiline 多行合并选项
+	Multiline *ElasticMultiline `json:"multiline,omitempty"`
+	// JSON JSON 解码选项
+	JSON *ElasticJSON `json:"json,omitempty"`
+	// Processors 处理器列表，每一项形如 {"<processor>": {<选项>}}
+	Processors []map[string]interface{} `json:"processors,omitempty"`
+}
+
+// ElasticMultiline 对应 co.elastic.logs/multiline.* hints.
+type ElasticMultiline struct {
+	Type    string `json:"type,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Negate  *bool  `json:"negate,omitempty"`
+	Match   string `json:"match,omitempty"`
+	// CountLines count 类型每次合并的行数
+	CountLines int `json:"count_lines,omitempty"`
+	// MaxLines 合并后单个事件的行数上限
+	MaxLines int    `json:"max_lines,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+}
+
+// ElasticJSON 对应 co.elastic.logs/json.* hints.
+type ElasticJSON struct {
+	KeysUnderRoot *bool  `json:"keys_under_root,omitempty"`
+	AddErrorKey   *bool  `json:"add_error_key,omitempty"`
+	MessageKey    string `json:"message_key,omitempty"`
+	OverwriteKeys *bool  `json:"overwrite_keys,omitempty"`
+	ExpandKeys    *bool  `json:"expand_keys,omitempty"`
+}
+
+// Valid 校验 elastic-hints 选项，并检查渲染出的 hint 键是否为合法的注解键.
+func (o *ElasticHintsOptions) Valid() error {
+	var errs FieldErrors
+	if o.Multiline != nil {
+		errs.merge(o.Multiline.Valid())
+	}
+	for i, processor := range o.Processors {
+		field := fieldIndex("profile_options.processors", i)
+		if len(processor) != 1 {
+			errs.add(field, "must have exactly one processor name")
+			continue
+		}
+		for name, config := range processor {
+			if !identifierPattern.MatchString(name) {
+				errs.add(field, "has invalid processor name %q", name)
+			} else if _, ok := config.(map[string]interface{}); !ok {
+				errs.add(field+"."+name, "must be an object")
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, key := range sortedKeys(o.render(sampleContainerName, []string{"/var/log/sample.log"})) {
+		if err := validateQualifiedName(key); err != nil {
+			errs.add("profile_options", "render invalid hint key %q: %v", key, err)
+		}
+	}
+	return errs.err()
+}
+
+// Valid 校验多行合并选项.
+func (m *ElasticMultiline) Valid() error {
+	var errs FieldErrors
+	switch m.Type {
+	case "", "pattern", "while_pattern":
+		if m.Pattern == "" {
+			errs.add("profile_options.multiline.pattern", "cannot be empty")
+		} else if _, err := regexp.Compile(m.Pattern); err != nil {
+			errs.add("profile_options.multiline.pattern", "is not a valid regular expression: %v", err)
+		}
+		if m.CountLines != 0 {
+			errs.add("profile_options.multiline.count_lines", "is only supported by the count type")
+		}
+	case "count":
+		if m.CountLines <= 0 {
+			errs.add("profile_options.multiline.count_lines", "must be positive for the count type")
+		}
+	default:
+		errs.add("profile_options.multiline.type", "must be pattern, while_pattern or count, got %q", m.Type)
+	}
+	switch m.Match {
+	case "", "after", "before":
+	default:
+		errs.add("profile_options.multiline.match", "must be after or before, got %q", m.Match)
+	}
+	if m.MaxLines < 0 {
+		errs.add("profile_options.multiline.max_lines", "cannot be negative")
+	}
+	return errs.err()
+}
+
+// Render 生成 Filebeat hints 注解，per_container 时每个容器各自一组，否则全部容器的路径写入同一组.
+func (o *ElasticHintsOptions) Render(result *DiscoveryResult) (map[string]string, error) {
+	if !o.PerContainer {
+		return renderMerged(result, func(logPaths []string) map[string]string {
+			return o.render("", logPaths)
+		}), nil
+	}
+	return renderContainers(result, func(containerName string, logPaths []string) (map[string]string, error) {
+		return o.render(containerName, logPaths), nil
+	})
+}
+
+// render 生成容器的 Filebeat hints 注解.
+// paths 不是 Filebeat 内置的 hint，需要在 autodiscover 模板中读取该注解才会采集这些文件.
+func (o *ElasticHintsOptions) render(containerName string, logPaths []string) map[string]string {
+	prefix := elasticHintsPrefix + "/"
+	if o.PerContainer {
+		prefix = elasticHintsPrefix + "." + containerName + "/"
+	}
+
+	hints := map[string]string{}
+	if o.Enabled != nil && !*o.Enabled {
+		hints[prefix+"enabled"] = "false"
+		return hints
+	}
+	hints[prefix+"enabled"] = "true"
+	hints[prefix+"paths"] = strings.Join(logPaths, ",")
+
+	if m := o.Multiline; m != nil {
+		setHint(hints, prefix+"multiline.type", m.Type)
+		setHint(hints, prefix+"multiline.pattern", m.Pattern)
+		setBoolHint(hints, prefix+"multiline.negate", m.Negate)
+		setHint(hints, prefix+"multiline.match", m.Match)
+		if m.CountLines > 0 {
+			hints[prefix+"multiline.count_lines"] = strconv.Itoa(m.CountLines)
+		}
+		if m.MaxLines > 0 {
+			hints[prefix+"multiline.max_lines"] = strconv.Itoa(m.MaxLines)
+		}
+		setHint(hints, prefix+"multiline.timeout", m.Timeout)
+	}
+	if j := o.JSON; j != nil {
+		setBoolHint(hints, prefix+"json.keys_under_root", j.KeysUnderRoot)
+		setBoolHint(hints, prefix+"json.add_error_key", j.AddErrorKey)
+		setHint(hints, prefix+"json.message_key", j.MessageKey)
+		setBoolHint(hints, prefix+"json.overwrite_keys", j.OverwriteKeys)
+		setBoolHint(hints, prefix+"json.expand_keys", j.ExpandKeys)
+	}
+	for i, processor := range o.Processors {
+		flattenHint(hints, fmt.Sprintf("%sprocessors.%d", prefix, i+1), processor)
+	}
+	return hints
+}
+
+func setHint(hints map[string]string, key, value string) {
+	if value != "" {
+		hints[key] = value
+	}
+}
+
+func setBoolHint(hints map[string]string, key string, value *bool) {
+	if value != nil {
+		hints[key] = strconv.FormatBool(*value)
+	}
+}
+
+// flattenHint 把嵌套的对象展开为以点分隔的 hint 键，数组以 JSON 字符串输出.
+func flattenHint(hints map[string]string, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			flattenHint(hints, key+"."+name, v[name])
+		}
+	case []interface{}:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			hints[key] = string(encoded)
+		}
+	case nil:
+	default:
+		hints[key] = convertToString(v)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestElasticHintsOptionsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		expectedError string
+	}{
+		{name: "no options", options: `{}`},
+		{
+			name: "complete options",
+			options: `{
+				"per_container": true,
+				"multiline": {"pattern": "^\\[", "negate": true, "match": "after"},
+				"json": {"keys_under_root": true, "message_key": "msg"},
+				"processors": [{"add_fields": {"target": "app", "fields": {"team": "payments"}}}]
+			}`,
+		},
+		{
+			name:          "unknown field",
+			options:       `{"multilines": {}}`,
+			expectedError: `profile_options: json: unknown field "multilines"`,
+		},
+		{
+			name:          "multiline without pattern",
+			options:       `{"multiline": {"match": "after"}}`,
+			expectedError: "profile_options.multiline.pattern: cannot be empty",
+		},
+		{
+			name:          "invalid multiline match",
+			options:       `{"multiline": {"pattern": "^\\s", "match": "later"}}`,
+			expectedError: `profile_options.multiline.match: must be after or before, got "later"`,
+		},
+		{
+			name:          "count multiline without count lines",
+			options:       `{"multiline": {"type": "count", "max_lines": 500}}`,
+			expectedError: "profile_options.multiline.count_lines: must be positive for the count type",
+		},
+		{
+			name:          "count lines with pattern multiline",
+			options:       `{"multiline": {"pattern": "^\\s", "count_lines": 3}}`,
+			expectedError: "profile_options.multiline.count_lines: is only supported by the count type",
+		},
+		{
+			name:          "negative max lines",
+			options:       `{"multiline": {"type": "count", "count_lines": 3, "max_lines": -1}}`,
+			expectedError: "profile_options.multiline.max_lines: cannot be negative",
+		},
+		{
+			name:          "processor with two names",
+			options:       `{"processors": [{"add_fields": {}, "drop_fields": {}}]}`,
+			expectedError: "profile_options.processors[0]: must have exactly one processor name",
+		},
+		{
+			name:          "processor without options object",
+			options:       `{"processors": [{"decode_json_fields": "message"}]}`,
+			expectedError: "profile_options.processors[0].decode_json_fields: must be an object",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileElasticHints, ProfileOptions: json.RawMessage(test.options)}
+			err := settings.validProfile()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestElasticHintsRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  string
+		expected map[string]string
+	}{
+		{
+			name:    "pod level hints",
+			options: `{"multiline": {"pattern": "^[[:space:]]", "negate": false, "match": "after"}}`,
+			expected: map[string]string{
+				"co.elastic.logs/enabled":           "true",
+				"co.elastic.logs/paths":             "/var/log/app.log,/var/log/error.log",
+				"co.elastic.logs/multiline.pattern": "^[[:space:]]",
+				"co.elastic.logs/multiline.negate":  "false",
+				"co.elastic.logs/multiline.match":   "after",
+			},
+		},
+		{
+			name: "container level hints",
+			options: `{
+				"per_container": true,
+				"json": {"keys_under_root": true, "add_error_key": true, "message_key": "msg"},
+				"processors": [
+					{"add_fields": {"target": "app", "fields": {"team": "payments"}}},
+					{"drop_fields": {"fields": ["agent", "ecs"]}}
+				]
+			}`,
+			expected: map[string]string{
+				"co.elastic.logs.app/enabled":                             "true",
+				"co.elastic.logs.app/paths":                               "/var/log/app.log,/var/log/error.log",
+				"co.elastic.logs.app/json.keys_under_root":                "true",
+				"co.elastic.logs.app/json.add_error_key":                  "true",
+				"co.elastic.logs.app/json.message_key":                    "msg",
+				"co.elastic.logs.app/processors.1.add_fields.target":      "app",
+				"co.elastic.logs.app/processors.1.add_fields.fields.team": "payments",
+				"co.elastic.logs.app/processors.2.drop_fields.fields":     `["agent","ecs"]`,
+			},
+		},
+		{
+			name:    "count multiline",
+			options: `{"multiline": {"type": "count", "count_lines": 3, "max_lines": 10}}`,
+			expected: map[string]string{
+				"co.elastic.logs/enabled":               "true",
+				"co.elastic.logs/paths":                 "/var/log/app.log,/var/log/error.log",
+				"co.elastic.logs/multiline.type":        "count",
+				"co.elastic.logs/multiline.count_lines": "3",
+				"co.elastic.logs/multiline.max_lines":   "10",
+			},
+		},
+		{
+			name:    "disabled",
+			options: `{"enabled": false, "multiline": {"pattern": "^\\s"}}`,
+			expected: map[string]string{
+				"co.elastic.logs/enabled": "false",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileElasticHints, ProfileOptions: json.RawMessage(test.options)}
+			hints, err := renderContainer(settings, "app", []string{"/var/log/app.log", "/var/log/error.log"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(hints, test.expected) {
+				t.Errorf("Expected hints %v, got %v", test.expected, hints)
+			}
+		})
+	}
+}
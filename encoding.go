@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// EncodingNumbered 每个日志路径写入一个编号的注解，为默认编码.
+	EncodingNumbered = "numbered"
+	// EncodingJoined 全部日志路径以 separator 拼接后写入 annotation_base.
+	EncodingJoined = "joined"
+	// EncodingJSONArray 全部日志路径以 JSON 数组写入 annotation_base.
+	EncodingJSONArray = "json-array"
+	// EncodingYAMLList 全部日志路径以 YAML 列表写入 annotation_base.
+	EncodingYAMLList = "yaml-list"
+	defaultSeparator = ","
+)
+
+// plainYAMLPattern 匹配可以不加引号写入 YAML 的路径.
+//
+//nolint:gochecknoglobals // 预编译的正则表达式只读.
+var plainYAMLPattern = regexp.MustCompile(`^[/A-Za-z0-9_.][-/A-Za-z0-9_.*]*$`)
+
+// validEncoding 校验 encoding 及其相关配置.
+func (s *Settings) validEncoding() error {
+	var errs FieldErrors
+	switch s.Encoding {
+	case "", EncodingNumbered:
+		if s.Separator != "" {
+			errs.add("separator", "only supported by the joined encoding")
+		}
+		return errs.err()
+	case EncodingJoined:
+		if strings.ContainsRune(s.Separator, 0) {
+			errs.add("separator", "must not contain NUL bytes")
+		}
+	case EncodingJSONArray, EncodingYAMLList:
+		if s.Separator != "" {
+			errs.add("separator", "only supported by the joined encoding")
+		}
+	default:
+		errs.add("encoding", "must be one of %s, %s, %s or %s, got %q",
+			EncodingNumbered, EncodingJoined, EncodingJSONArray, EncodingYAMLList, s.Encoding)
+		return errs.err()
+	}
+
+	// 非 numbered 编码把全部路径写入 annotation_base，编号相关的配置不再生效
+	if s.AnnotationBase == "" {
+		errs.add("annotation_base", "cannot be empty with the %s encoding", s.Encoding)
+	}
+	if !s.firstPathUsesBase() {
+		errs.add("first_path_key", "%s is only supported by the numbered encoding", FirstPathKeyExt)
+	}
+	return errs.err()
+}
+
+func (s *Settings) isNumbered() bool {
+	return s.Encoding == "" || s.Encoding == EncodingNumbered
+}
+
+func (s *Settings) separator() string {
+	if s.Separator == "" {
+		return defaultSeparator
+	}
+	return s.Separator
+}
+
+// encodePaths 按非 numbered 编码把全部日志路径编码为一个注解值.
+func (s *Settings) encodePaths(logPaths []string) string {
+	switch s.Encoding {
+	case EncodingJSONArray:
+		encoded, err := json.Marshal(logPaths)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	case EncodingYAMLList:
+		lines := make([]string, 0, len(logPaths))
+		for _, logPath := range logPaths {
+			lines = append(lines, "- "+yamlScalar(logPath))
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return strings.Join(logPaths, s.separator())
+	}
+}
+
+// checkEncodable 检查日志路径能否无歧义地编码，joined 编码下路径不能包含分隔符.
+func (s *Settings) checkEncodable(logPaths []string) error {
+	if s.Encoding != EncodingJoined || !s.usesBaseExt() {
+		return nil
+	}
+	for _, logPath := range logPaths {
+		if strings.Contains(logPath, s.separator()) {
+			return fmt.Errorf("log path %q contains the separator %q of the joined encoding", logPath, s.separator())
+		}
+	}
+	return nil
+}
+
+// yamlScalar 返回路径的 YAML 标量写法，只有普通路径字符时不加引号.
+func yamlScalar(value string) string {
+	if plainYAMLPattern.MatchString(value) {
+		return value
+	}
+	// JSON 字符串同时也是合法的 YAML 双引号标量
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(quoted)
+}
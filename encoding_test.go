@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestValidEncoding(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{name: "default", settings: Settings{}},
+		{name: "joined", settings: Settings{AnnotationBase: "paths", Encoding: EncodingJoined, Separator: ";"}},
+		{name: "yaml list", settings: Settings{AnnotationBase: "paths", Encoding: EncodingYAMLList}},
+		{
+			name:          "unknown encoding",
+			settings:      Settings{AnnotationBase: "paths", Encoding: "csv"},
+			expectedError: `encoding: must be one of numbered, joined, json-array or yaml-list, got "csv"`,
+		},
+		{
+			name:          "separator with numbered encoding",
+			settings:      Settings{Separator: ";"},
+			expectedError: "separator: only supported by the joined encoding",
+		},
+		{
+			name:          "separator with json array",
+			settings:      Settings{AnnotationBase: "paths", Encoding: EncodingJSONArray, Separator: ";"},
+			expectedError: "separator: only supported by the joined encoding",
+		},
+		{
+			name:     "missing annotation base",
+			settings: Settings{Encoding: EncodingJSONArray, FirstPathKey: FirstPathKeyExt},
+			expectedError: "annotation_base: cannot be empty with the json-array encoding; " +
+				"first_path_key: ext is only supported by the numbered encoding",
+		},
+		{
+			name:          "ext first path key",
+			settings:      Settings{AnnotationBase: "paths", Encoding: EncodingJoined, FirstPathKey: FirstPathKeyExt},
+			expectedError: "first_path_key: ext is only supported by the numbered encoding",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.validEncoding()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestPathListEncodings(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("my-container"),
+							Env: []*corev1.EnvVar{
+								{Name: stringPtr("LOG"), Value: "/var/log/app.log"},
+								{Name: stringPtr("LOG"), Value: "/var/log/app #2.log"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		encoding      string
+		separator     string
+		expectedValue string
+		expectedError string
+	}{
+		{
+			name:          "joined with default separator",
+			encoding:      EncodingJoined,
+			expectedValue: "/var/log/app.log,/var/log/app #2.log",
+		},
+		{
+			name:          "joined with custom separator",
+			encoding:      EncodingJoined,
+			separator:     ";",
+			expectedValue: "/var/log/app.log;/var/log/app #2.log",
+		},
+		{
+			name:          "joined with a separator inside a path",
+			encoding:      EncodingJoined,
+			separator:     "#",
+			expectedError: `log path "/var/log/app #2.log" contains the separator "#" of the joined encoding`,
+		},
+		{
+			name:          "json array",
+			encoding:      EncodingJSONArray,
+			expectedValue: `["/var/log/app.log","/var/log/app #2.log"]`,
+		},
+		{
+			name:          "yaml list",
+			encoding:      EncodingYAMLList,
+			expectedValue: "- /var/log/app.log\n- \"/var/log/app #2.log\"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{
+				EnvKey:         "LOG",
+				AnnotationBase: "example.com/log-paths",
+				Encoding:       test.encoding,
+				Separator:      test.separator,
+			}
+
+			plan, err := planLogAnnotations(deployment, settings)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(plan.annotations) != 1 {
+				t.Errorf("Expected a single annotation, got %v", plan.annotations)
+			}
+			if value := plan.annotations["example.com/log-paths"]; value != test.expectedValue {
+				t.Errorf("Expected value %q, got %q", test.expectedValue, value)
+			}
+		})
+	}
+}
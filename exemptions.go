@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const serviceAccountUserPrefix = "system:serviceaccount:"
+
+// RequesterExemptions 定义了不做修改的请求发起者，支持精确名称和 glob 模式.
+type RequesterExemptions struct {
+	// Users 用户名，例如 system:admin
+	Users []string `json:"users,omitempty"`
+	// Groups 用户组，例如 system:masters
+	Groups []string `json:"groups,omitempty"`
+	// ServiceAccounts <namespace>/<name> 形式的 ServiceAccount，例如 argocd/argocd-application-controller
+	ServiceAccounts []string `json:"service_accounts,omitempty"`
+}
+
+// Valid 校验豁免列表中的模式.
+func (e *RequesterExemptions) Valid() error {
+	var errs FieldErrors
+	validGlobPatterns(&errs, "exemptions.users", e.Users)
+	validGlobPatterns(&errs, "exemptions.groups", e.Groups)
+	validGlobPatterns(&errs, "exemptions.service_accounts", e.ServiceAccounts)
+	for i, serviceAccount := range e.ServiceAccounts {
+		if serviceAccount != "" && strings.Count(serviceAccount, "/") != 1 {
+			errs.add(fieldIndex("exemptions.service_accounts", i), "%q must have the form <namespace>/<name>",
+				serviceAccount)
+		}
+	}
+	return errs.err()
+}
+
+// exemptionReason 返回请求发起者被豁免的原因，未被豁免时返回空字符串.
+func (e *RequesterExemptions) exemptionReason(userInfo kubewarden_protocol.UserInfo) string {
+	if pattern, ok := matchingPattern(userInfo.Username, e.Users); ok {
+		return fmt.Sprintf("user %q matches exemptions.users entry %q", userInfo.Username, pattern)
+	}
+	for _, group := range userInfo.Groups {
+		if pattern, ok := matchingPattern(group, e.Groups); ok {
+			return fmt.Sprintf("group %q matches exemptions.groups entry %q", group, pattern)
+		}
+	}
+	// ServiceAccount 的用户名形如 system:serviceaccount:<namespace>:<name>
+	if name, ok := strings.CutPrefix(userInfo.Username, serviceAccountUserPrefix); ok {
+		serviceAccount := strings.Replace(name, ":", "/", 1)
+		if pattern, ok := matchingPattern(serviceAccount, e.ServiceAccounts); ok {
+			return fmt.Sprintf("service account %q matches exemptions.service_accounts entry %q",
+				serviceAccount, pattern)
+		}
+	}
+	return ""
+}
+
+// matchingPattern 返回第一个匹配 value 的模式.
+func matchingPattern(value string, patterns []string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
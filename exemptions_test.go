@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestRequesterExemptionsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		exemptions    RequesterExemptions
+		expectedError string
+	}{
+		{name: "no exemptions", exemptions: RequesterExemptions{}},
+		{
+			name: "names and patterns",
+			exemptions: RequesterExemptions{
+				Users:           []string{"system:admin", "*@platform.example.com"},
+				Groups:          []string{"system:masters"},
+				ServiceAccounts: []string{"argocd/*", "flux-system/kustomize-controller"},
+			},
+		},
+		{
+			name:          "empty user",
+			exemptions:    RequesterExemptions{Users: []string{""}},
+			expectedError: "exemptions.users[0]: cannot be empty",
+		},
+		{
+			name:          "malformed group pattern",
+			exemptions:    RequesterExemptions{Groups: []string{"team-[a"}},
+			expectedError: `exemptions.groups[0]: "team-[a" is not a valid glob pattern: syntax error in pattern`,
+		},
+		{
+			name:       "service account without namespace",
+			exemptions: RequesterExemptions{ServiceAccounts: []string{"argocd-application-controller"}},
+			expectedError: `exemptions.service_accounts[0]: "argocd-application-controller" ` +
+				"must have the form <namespace>/<name>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.exemptions.Valid()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRequesterExemptionReason(t *testing.T) {
+	exemptions := RequesterExemptions{
+		Users:           []string{"*@platform.example.com"},
+		Groups:          []string{"gitops-*"},
+		ServiceAccounts: []string{"argocd/*"},
+	}
+	tests := []struct {
+		name     string
+		userInfo kubewarden_protocol.UserInfo
+		expected string
+	}{
+		{
+			name:     "user pattern",
+			userInfo: kubewarden_protocol.UserInfo{Username: "jane@platform.example.com"},
+			expected: `user "jane@platform.example.com" matches exemptions.users entry "*@platform.example.com"`,
+		},
+		{
+			name: "group pattern",
+			userInfo: kubewarden_protocol.UserInfo{
+				Username: "bot",
+				Groups:   []string{"system:authenticated", "gitops-bots"},
+			},
+			expected: `group "gitops-bots" matches exemptions.groups entry "gitops-*"`,
+		},
+		{
+			name: "service account",
+			userInfo: kubewarden_protocol.UserInfo{
+				Username: "system:serviceaccount:argocd:argocd-application-controller",
+			},
+			expected: `service account "argocd/argocd-application-controller" matches ` +
+				`exemptions.service_accounts entry "argocd/*"`,
+		},
+		{
+			name:     "service account in another namespace",
+			userInfo: kubewarden_protocol.UserInfo{Username: "system:serviceaccount:default:argocd"},
+		},
+		{
+			name:     "regular user",
+			userInfo: kubewarden_protocol.UserInfo{Username: "jane@example.com", Groups: []string{"developers"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := exemptions.exemptionReason(test.userInfo); reason != test.expected {
+				t.Errorf("Expected reason %q, got %q", test.expected, reason)
+			}
+		})
+	}
+}
+
+func TestExemptRequesterIsNotMutated(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Exemptions:          RequesterExemptions{ServiceAccounts: []string{"flux-system/*"}},
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{{
+						Name: stringPtr("app"),
+						Env:  []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"}},
+					}},
+				},
+			},
+		},
+	}
+	req := kubewarden_protocol.ValidationRequest{
+		Request: kubewarden_protocol.KubernetesAdmissionRequest{
+			Uid:      "705ab4f5-6393-11e8-b7cc-42010a800002",
+			Kind:     kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+			UserInfo: kubewarden_protocol.UserInfo{Username: "system:serviceaccount:flux-system:kustomize-controller"},
+			Object:   json.RawMessage(mustMarshalJSON(deployment)),
+		},
+		Settings: json.RawMessage(mustMarshalJSON(settings)),
+	}
+
+	response, err := validateTest(t, req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertNoMutation(t, response)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxExtIndexWidth 是序号宽度的上限，与注解名称的最大长度一致.
+	maxExtIndexWidth = 63
+	// FirstPathKeyBase 第一个日志路径写入 annotation_base，为默认行为.
+	FirstPathKeyBase = "base"
+	// FirstPathKeyExt 第一个日志路径与后续路径一样使用 annotation_ext_format.
+	FirstPathKeyExt   = "ext"
+	defaultIndexStart = 1
+)
+
+// extKeyFormat 是解析后的 annotation_ext_format，由前缀、唯一的整数占位符和后缀组成.
+type extKeyFormat struct {
+	prefix string
+	verb   string
+	suffix string
+}
+
+// parseExtKeyFormat 解析 annotation_ext_format.
+// 格式中必须有且只有一个整数占位符，允许 %02d 这样的零填充宽度写法，%% 表示字面量 %，其它占位符均会被拒绝.
+func parseExtKeyFormat(format string) (extKeyFormat, error) {
+	var parsed extKeyFormat
+	var literal strings.Builder
+	found := false
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+
+		verb, err := scanIntegerVerb(format, i)
+		if err != nil {
+			return extKeyFormat{}, err
+		}
+		if found {
+			return extKeyFormat{}, fmt.Errorf("%q has a second placeholder at position %d, "+
+				"exactly one %%d placeholder is allowed", format, i+1)
+		}
+		found = true
+		parsed.prefix = literal.String()
+		parsed.verb = verb
+		literal.Reset()
+		i += len(verb) - 1
+	}
+
+	if !found {
+		return extKeyFormat{}, errors.New("must contain %d placeholder")
+	}
+	parsed.suffix = literal.String()
+	return parsed, nil
+}
+
+// scanIntegerVerb 从 start 处的 % 开始读取 %[0][width]d 形式的占位符.
+func scanIntegerVerb(format string, start int) (string, error) {
+	end := start + 1
+	if end < len(format) && format[end] == '0' {
+		end++
+	}
+	width := 0
+	for end < len(format) && format[end] >= '0' && format[end] <= '9' {
+		width = width*10 + int(format[end]-'0') //nolint:mnd // 十进制
+		end++
+	}
+
+	switch {
+	case end >= len(format):
+		return "", fmt.Errorf("%q has an incomplete placeholder at position %d",
+			format, start+1)
+	case format[end] != 'd':
+		return "", fmt.Errorf("%q has unsupported verb %q at position %d, "+
+			"only %%d with an optional zero-padded width such as %%02d is allowed",
+			format, format[start:end+1], start+1)
+	case width > maxExtIndexWidth:
+		return "", fmt.Errorf("%q has a width larger than %d at position %d",
+			format, maxExtIndexWidth, start+1)
+	}
+	return format[start : end+1], nil
+}
+
+// render 生成序号为 index 的扩展注解键，padding 大于 0 时以该宽度零填充并忽略占位符自身的宽度.
+func (f extKeyFormat) render(index, padding int) string {
+	if padding > 0 {
+		return f.prefix + fmt.Sprintf("%0*d", padding, index) + f.suffix
+	}
+	return f.prefix + fmt.Sprintf(f.verb, index) + f.suffix
+}
+
+// matches 判断 key 是否为该格式以任意序号渲染出的键，%3d 这样的宽度会以空格填充.
+func (f extKeyFormat) matches(key string) bool {
+	index, ok := strings.CutPrefix(key, f.prefix)
+	if !ok {
+		return false
+	}
+	if index, ok = strings.CutSuffix(index, f.suffix); !ok {
+		return false
+	}
+	digits := strings.TrimLeft(index, " ")
+	return digits != "" && strings.Trim(digits, "0123456789") == ""
+}
+
+// isBaseExtPathKey 判断 key 是否为 base-ext profile 写入日志路径的 annotation_base 或编号键.
+func (s *Settings) isBaseExtPathKey(key string) bool {
+	if !s.usesBaseExt() {
+		return false
+	}
+	if key == s.AnnotationBase {
+		return true
+	}
+	format, err := parseExtKeyFormat(s.AnnotationExtFormat)
+	return s.isNumbered() && err == nil && format.matches(key)
+}
+
+// validNumbering 校验序号起始值、零填充宽度和第一个路径使用的键.
+func (s *Settings) validNumbering() error {
+	var errs FieldErrors
+	if s.IndexStart != nil && *s.IndexStart < 0 {
+		errs.add("index_start", "cannot be negative")
+	}
+	if s.IndexPadding < 0 || s.IndexPadding > maxExtIndexWidth {
+		errs.add("index_padding", "must be between 0 and %d", maxExtIndexWidth)
+	}
+	switch s.FirstPathKey {
+	case "", FirstPathKeyBase, FirstPathKeyExt:
+	default:
+		errs.add("first_path_key", "must be %s or %s, got %q", FirstPathKeyBase, FirstPathKeyExt, s.FirstPathKey)
+	}
+	return errs.err()
+}
+
+func (s *Settings) firstPathUsesBase() bool {
+	return s.FirstPathKey != FirstPathKeyExt
+}
+
+func (s *Settings) indexStart() int {
+	if s.IndexStart == nil {
+		return defaultIndexStart
+	}
+	return *s.IndexStart
+}
+
+// pathKey 返回第 i 个(从 0 开始)日志路径使用的注解键.
+// 默认第一个路径使用 annotation_base，其余路径从 index_start 开始编号.
+func (s *Settings) pathKey(i int) string {
+	if !s.firstPathUsesBase() {
+		return s.extKey(s.indexStart() + i)
+	}
+	if i == 0 {
+		return s.AnnotationBase
+	}
+	return s.extKey(s.indexStart() + i - 1)
+}
+
+// extKey 按 annotation_ext_format 和 index_padding 生成序号为 index 的扩展注解键.
+func (s *Settings) extKey(index int) string {
+	format, err := parseExtKeyFormat(s.AnnotationExtFormat)
+	if err != nil {
+		// 配置已在加载时校验，这里只为防御未经校验的配置
+		return fmt.Sprintf(s.AnnotationExtFormat, index)
+	}
+	return format.render(index, s.IndexPadding)
+}
@@ -0,0 +1,179 @@
+package main
+
+import "testing"
+
+func TestParseExtKeyFormat(t *testing.T) {
+	tests := []struct {
+		format      string
+		index       int
+		expectedKey string
+		expectedErr string
+	}{
+		{format: "co_elastic_logs_path_ext_%d", index: 3, expectedKey: "co_elastic_logs_path_ext_3"},
+		{format: "log_%02d", index: 3, expectedKey: "log_03"},
+		{format: "log_%3d_x", index: 12, expectedKey: "log_ 12_x"},
+		{format: "example.com/%d-log", index: 1, expectedKey: "example.com/1-log"},
+		{format: "log_%%_%d", index: 1, expectedKey: "log_%_1"},
+		{format: "log_%%d_%d", index: 2, expectedKey: "log_%d_2"},
+		{format: "test_ext", expectedErr: "must contain %d placeholder"},
+		{format: "log_%%d", expectedErr: "must contain %d placeholder"},
+		{
+			format: "log_%d_%d",
+			expectedErr: `"log_%d_%d" has a second placeholder at position 8, ` +
+				"exactly one %d placeholder is allowed",
+		},
+		{
+			format: "log_%s_%d",
+			expectedErr: `"log_%s_%d" has unsupported verb "%s" at position 5, ` +
+				"only %d with an optional zero-padded width such as %02d is allowed",
+		},
+		{
+			format: "log_%d_%v",
+			expectedErr: `"log_%d_%v" has unsupported verb "%v" at position 8, ` +
+				"only %d with an optional zero-padded width such as %02d is allowed",
+		},
+		{
+			format: "log_%-2d",
+			expectedErr: `"log_%-2d" has unsupported verb "%-" at position 5, ` +
+				"only %d with an optional zero-padded width such as %02d is allowed",
+		},
+		{
+			format:      "log_%02",
+			expectedErr: `"log_%02" has an incomplete placeholder at position 5`,
+		},
+		{
+			format:      "log_%099d",
+			expectedErr: `"log_%099d" has a width larger than 63 at position 5`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			format, err := parseExtKeyFormat(test.format)
+			if test.expectedErr != "" {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("Expected error %q, got: %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if key := format.render(test.index, 0); key != test.expectedKey {
+				t.Errorf("Expected key %q, got %q", test.expectedKey, key)
+			}
+		})
+	}
+}
+
+func TestExtKeyFormatMatches(t *testing.T) {
+	tests := []struct {
+		format   string
+		key      string
+		expected bool
+	}{
+		{format: "co_elastic_logs_path_ext_%d", key: "co_elastic_logs_path_ext_7", expected: true},
+		{format: "co_elastic_logs_path_ext_%d", key: "co_elastic_logs_path_ext_", expected: false},
+		{format: "co_elastic_logs_path_ext_%d", key: "co_elastic_logs_path_ext_x", expected: false},
+		{format: "log_%02d", key: "log_123", expected: true},
+		{format: "log_%3d_x", key: "log_ 12_x", expected: true},
+		{format: "log_%3d_x", key: "log_12_y", expected: false},
+		{format: "example.com/%d-log", key: "example.com/1-log", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format+" "+test.key, func(t *testing.T) {
+			format, err := parseExtKeyFormat(test.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if matched := format.matches(test.key); matched != test.expected {
+				t.Errorf("Expected matches(%q) to be %t, got %t", test.key, test.expected, matched)
+			}
+		})
+	}
+}
+
+func TestPathKey(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name         string
+		settings     Settings
+		expectedKeys []string
+	}{
+		{
+			name:         "default numbering",
+			settings:     Settings{AnnotationBase: "path", AnnotationExtFormat: "path_ext_%d"},
+			expectedKeys: []string{"path", "path_ext_1", "path_ext_2"},
+		},
+		{
+			name: "every path in ext keys starting at zero",
+			settings: Settings{
+				AnnotationExtFormat: "path_%d",
+				IndexStart:          &zero,
+				FirstPathKey:        FirstPathKeyExt,
+			},
+			expectedKeys: []string{"path_0", "path_1", "path_2"},
+		},
+		{
+			name: "zero padding overrides the format width",
+			settings: Settings{
+				AnnotationBase:      "path",
+				AnnotationExtFormat: "path_%d",
+				IndexPadding:        3,
+			},
+			expectedKeys: []string{"path", "path_001", "path_002"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, expected := range test.expectedKeys {
+				if key := test.settings.pathKey(i); key != expected {
+					t.Errorf("Expected key %q for path %d, got %q", expected, i, key)
+				}
+			}
+		})
+	}
+}
+
+func TestValidNumbering(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{name: "defaults", settings: Settings{}},
+		{
+			name:          "negative start",
+			settings:      Settings{IndexStart: &negative},
+			expectedError: "index_start: cannot be negative",
+		},
+		{
+			name:          "wide padding",
+			settings:      Settings{IndexPadding: 64},
+			expectedError: "index_padding: must be between 0 and 63",
+		},
+		{
+			name:          "unknown first path key",
+			settings:      Settings{FirstPathKey: "first"},
+			expectedError: `first_path_key: must be base or ext, got "first"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.validNumbering()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError 是一条配置错误，Field 以 JSON 路径的形式定位出错的字段，例如 additional_annotations["x"].
+type FieldError struct {
+	// Field 出错字段的定位，为空时表示无法定位到具体字段
+	Field string
+	// Message 错误描述
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors 是校验配置时收集到的全部错误，按发现的顺序排列.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add 记录 field 上的一条错误.
+func (e *FieldErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// merge 合并其他校验函数返回的错误，无法定位字段的错误以 Field 为空的形式保留.
+func (e *FieldErrors) merge(err error) {
+	if err == nil {
+		return
+	}
+	var fieldErrs FieldErrors
+	var fieldErr *FieldError
+	switch {
+	case errors.As(err, &fieldErrs):
+		*e = append(*e, fieldErrs...)
+	case errors.As(err, &fieldErr):
+		*e = append(*e, fieldErr)
+	default:
+		*e = append(*e, &FieldError{Message: err.Error()})
+	}
+}
+
+// err 在没有错误时返回 nil，避免把空的 FieldErrors 当作错误返回.
+func (e FieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// list 把错误逐行列出，用于 validate_settings 的拒绝消息.
+func (e FieldErrors) list() string {
+	var builder strings.Builder
+	for _, fieldErr := range e {
+		builder.WriteString("\n- ")
+		builder.WriteString(fieldErr.Error())
+	}
+	return builder.String()
+}
+
+// fieldIndex 返回列表元素的定位，例如 containers.names[0].
+func fieldIndex(field string, index int) string {
+	return field + "[" + strconv.Itoa(index) + "]"
+}
+
+// fieldKey 返回 map 元素的定位，例如 additional_annotations["x"].
+func fieldKey(field, key string) string {
+	return field + "[" + strconv.Quote(key) + "]"
+}
+
+// sortedKeys 按字典序返回 map 的键，使错误的顺序不受 map 遍历顺序影响.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFieldErrorsMerge(t *testing.T) {
+	var errs FieldErrors
+	errs.merge(nil)
+	if errs.err() != nil {
+		t.Fatalf("Expected no error, got: %v", errs.err())
+	}
+
+	var nested FieldErrors
+	nested.add(fieldIndex("operations", 1), "must be CREATE or UPDATE, got %q", "DELETE")
+	nested.add(fieldKey("additional_labels", "team"), "value %q is not a valid label value", "a b")
+	errs.merge(nested)
+	errs.merge(fmt.Errorf("wrapped: %w", &FieldError{Field: "profile", Message: "unknown"}))
+	errs.merge(errors.New("plain error"))
+
+	expected := []string{
+		`operations[1]: must be CREATE or UPDATE, got "DELETE"`,
+		`additional_labels["team"]: value "a b" is not a valid label value`,
+		"profile: unknown",
+		"plain error",
+	}
+	var messages []string
+	for _, fieldErr := range errs {
+		messages = append(messages, fieldErr.Error())
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected errors %q, got %q", expected, messages)
+	}
+}
+
+func TestSettingsValidCollectsAllErrors(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "",
+		AnnotationBase:      "logs.example.com/path",
+		AnnotationExtFormat: "logs.example.com/path_%d",
+		AdditionalAnnotations: map[string]interface{}{
+			"x":                 "",
+			"multiline pattern": "^\\[",
+		},
+		Operations: []string{"DELETE"},
+		PathRules:  PathRules{AllowedRoots: []string{"var/log"}},
+	}
+
+	valid, err := settings.Valid()
+	if valid {
+		t.Fatal("Expected settings to be invalid")
+	}
+	var errs FieldErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected FieldErrors, got %T: %v", err, err)
+	}
+
+	fields := map[string]bool{}
+	for _, fieldErr := range errs {
+		fields[fieldErr.Field] = true
+	}
+	for _, field := range []string{
+		"env_key",
+		`additional_annotations["x"]`,
+		`additional_annotations["multiline pattern"]`,
+		"operations[0]",
+		"path_rules.allowed_roots[0]",
+	} {
+		if !fields[field] {
+			t.Errorf("Expected an error for %s, got: %v", field, err)
+		}
+	}
+}
+
+func TestValidateSettingsListsAllErrors(t *testing.T) {
+	payload := []byte(`{
+		"env_key": "",
+		"annotation_base": "logs.example.com/path",
+		"annotation_ext_format": "logs.example.com/path_%d",
+		"additional_annotations": {"x": ""},
+		"log_level": "trace"
+	}`)
+
+	response, err := validateSettings(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var result struct {
+		Valid   bool   `json:"valid"`
+		Message string `json:"message"`
+	}
+	if err = json.Unmarshal(response, &result); err != nil {
+		t.Fatalf("Cannot unmarshal response: %v", err)
+	}
+
+	expected := "Provided settings are not valid:" +
+		"\n- env_key: cannot be empty" +
+		"\n- additional_annotations[\"x\"]: string value cannot be empty" +
+		"\n- log_level: must be debug, info, warn or error, got \"trace\""
+	if result.Valid || result.Message != expected {
+		t.Errorf("Expected rejection %q, got: %s", expected, response)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const fluentBitAnnotationPrefix = "fluentbit.io/"
+
+// FluentBitOptions 定义了 fluentbit profile 的选项.
+type FluentBitOptions struct {
+	// PerContainer 是否在注解键后追加 -<container>，只作用于当前容器
+	PerContainer bool `json:"per_container,omitempty"`
+	// Stream 只作用于 stdout 或 stderr，为空时作用于两者
+	Stream string `json:"stream,omitempty"`
+	// Parser 输出 fluentbit.io/parser 注解时使用的 parser 名称
+	Parser string `json:"parser,omitempty"`
+	// Exclude 是否输出 fluentbit.io/exclude: "true"，避免日志写入文件的容器重复采集标准输出
+	Exclude bool `json:"exclude,omitempty"`
+	// PathsKey 以逗号分隔写入日志路径的注解键，为空时不输出路径
+	PathsKey string `json:"paths_key,omitempty"`
+}
+
+// Valid 校验 fluentbit 选项，并检查渲染出的注解键是否合法.
+func (o *FluentBitOptions) Valid() error {
+	var errs FieldErrors
+	switch o.Stream {
+	case "", "stdout", "stderr":
+	default:
+		errs.add("profile_options.stream", "must be stdout or stderr, got %q", o.Stream)
+	}
+	if strings.ContainsAny(o.Parser, " \t\r\n") {
+		errs.add("profile_options.parser", "%q must not contain whitespace", o.Parser)
+	}
+	if o.Parser == "" && !o.Exclude && o.PathsKey == "" {
+		errs.add("profile_options", "must set at least one of parser, exclude or paths_key")
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	annotations, err := o.render(sampleContainerName)
+	if err != nil {
+		errs.add("profile_options", "%v", err)
+		return errs
+	}
+	if o.PathsKey != "" {
+		annotations[o.PathsKey] = ""
+	}
+	for _, key := range sortedKeys(annotations) {
+		if err = validateQualifiedName(key); err != nil {
+			errs.add("profile_options", "render invalid annotation key %q: %v", key, err)
+		}
+	}
+	return errs.err()
+}
+
+// key 生成 fluentbit.io/<name>[_stream][-container] 形式的注解键.
+func (o *FluentBitOptions) key(name, containerName string) string {
+	key := fluentBitAnnotationPrefix + name
+	if o.Stream != "" {
+		key += "_" + o.Stream
+	}
+	if o.PerContainer {
+		key += "-" + containerName
+	}
+	return key
+}
+
+// Render 为每个容器生成 Fluent Bit 注解，paths_key 按容器顺序写入全部容器的日志路径.
+func (o *FluentBitOptions) Render(result *DiscoveryResult) (map[string]string, error) {
+	annotations, err := renderContainers(result, func(containerName string, _ []string) (map[string]string, error) {
+		return o.render(containerName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.PathsKey != "" && len(result.Containers) > 0 {
+		annotations[o.PathsKey] = strings.Join(result.LogPaths(), ",")
+	}
+	return annotations, nil
+}
+
+// render 生成容器的 parser 和 exclude 注解.
+func (o *FluentBitOptions) render(containerName string) (map[string]string, error) {
+	annotations := map[string]string{}
+	if o.Parser != "" {
+		annotations[o.key("parser", containerName)] = o.Parser
+	}
+	if o.Exclude {
+		annotations[o.key("exclude", containerName)] = "true"
+	}
+	for key := range annotations {
+		if err := validateQualifiedName(key); err != nil {
+			return nil, fmt.Errorf("container %q cannot be used in the fluentbit annotation key %q: %w",
+				containerName, key, err)
+		}
+	}
+	return annotations, nil
+}
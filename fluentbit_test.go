@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFluentBitOptionsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		expectedError string
+	}{
+		{name: "parser", options: `{"parser": "json"}`},
+		{name: "exclude per container", options: `{"exclude": true, "per_container": true, "stream": "stderr"}`},
+		{name: "paths key only", options: `{"paths_key": "logging.example.com/paths"}`},
+		{
+			name:          "nothing to render",
+			options:       `{"per_container": true}`,
+			expectedError: "profile_options: must set at least one of parser, exclude or paths_key",
+		},
+		{
+			name:          "unknown stream",
+			options:       `{"parser": "json", "stream": "both"}`,
+			expectedError: `profile_options.stream: must be stdout or stderr, got "both"`,
+		},
+		{
+			name:          "parser with whitespace",
+			options:       `{"parser": "my parser"}`,
+			expectedError: `profile_options.parser: "my parser" must not contain whitespace`,
+		},
+		{
+			name:    "invalid paths key",
+			options: `{"paths_key": "Logging/Paths"}`,
+			expectedError: `profile_options: render invalid annotation key "Logging/Paths": ` +
+				"prefix part must be a lowercase RFC 1123 subdomain of at most 253 characters",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileFluentBit, ProfileOptions: json.RawMessage(test.options)}
+			err := settings.validProfile()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestFluentBitRenderLongContainerName(t *testing.T) {
+	settings := Settings{
+		Profile:        ProfileFluentBit,
+		ProfileOptions: json.RawMessage(`{"parser": "json", "per_container": true}`),
+	}
+	_, err := renderContainer(settings, strings.Repeat("a", 60), []string{"/var/log/app.log"})
+	if err == nil || !strings.Contains(err.Error(), "cannot be used in the fluentbit annotation key") {
+		t.Errorf("Expected the container name to be rejected, got: %v", err)
+	}
+}
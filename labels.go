@@ -0,0 +1,67 @@
+package main
+
+import (
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+)
+
+// validLabels 校验 additional_labels 和 log_paths_label，标签值的规则比注解值严格.
+func (s *Settings) validLabels() error {
+	var errs FieldErrors
+	for _, key := range sortedKeys(s.AdditionalLabels) {
+		field := fieldKey("additional_labels", key)
+		if err := validateQualifiedName(key); err != nil {
+			errs.add(field, "key is not a valid label key: %v", err)
+		}
+		if err := validateLabelValue(s.AdditionalLabels[key]); err != nil {
+			errs.add(field, "value %q is not a valid label value: %v", s.AdditionalLabels[key], err)
+		}
+	}
+	if s.LogPathsLabel == "" {
+		return errs.err()
+	}
+	if err := validateQualifiedName(s.LogPathsLabel); err != nil {
+		errs.add("log_paths_label", "%q is not a valid label key: %v", s.LogPathsLabel, err)
+	}
+	if _, ok := s.AdditionalLabels[s.LogPathsLabel]; ok {
+		errs.add("log_paths_label", "%q is also set in additional_labels", s.LogPathsLabel)
+	}
+	return errs.err()
+}
+
+// planLabels 计算 Pod 模板应当携带的标签.
+// 被选中的容器声明了 env_key 时写入 additional_labels；log_paths_label 在找到日志路径时为 "true"，
+// 之前写入过而现在没有日志路径时改为 "false"，避免标签残留.
+func (s *Settings) planLabels(existing map[string]string, selected []containerLogPaths) map[string]string {
+	labels := map[string]string{}
+	if anyHasEnv(selected, s.EnvKey) {
+		for key, value := range s.AdditionalLabels {
+			labels[key] = value
+		}
+	}
+	if s.LogPathsLabel != "" {
+		if hasLogPaths(selected) {
+			labels[s.LogPathsLabel] = "true"
+		} else if _, ok := existing[s.LogPathsLabel]; ok {
+			labels[s.LogPathsLabel] = "false"
+		}
+	}
+	return labels
+}
+
+// templateLabels 返回 Pod 模板当前的标签，不存在时返回 nil.
+func templateLabels(deployment *appsv1.Deployment) map[string]string {
+	if deployment.Spec == nil || deployment.Spec.Template == nil || deployment.Spec.Template.Metadata == nil {
+		return nil
+	}
+	return deployment.Spec.Template.Metadata.Labels
+}
+
+// hasLogPaths 判断被选中的容器中是否有日志路径.
+func hasLogPaths(selected []containerLogPaths) bool {
+	for _, entry := range selected {
+		if len(entry.logPaths) > 0 {
+			return true
+		}
+	}
+	return false
+}
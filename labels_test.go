@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidLabels(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{name: "no labels", settings: Settings{}},
+		{
+			name: "labels",
+			settings: Settings{
+				AdditionalLabels: map[string]string{"logging.example.com/team": "payments", "tier": ""},
+				LogPathsLabel:    "logging.example.com/enabled",
+			},
+		},
+		{
+			name:     "invalid key",
+			settings: Settings{AdditionalLabels: map[string]string{"Logging/team": "payments"}},
+			expectedError: `additional_labels["Logging/team"]: key is not a valid label key: ` +
+				"prefix part must be a lowercase RFC 1123 subdomain of at most 253 characters",
+		},
+		{
+			name:     "invalid value",
+			settings: Settings{AdditionalLabels: map[string]string{"team": "payments team"}},
+			expectedError: `additional_labels["team"]: value "payments team" is not a valid label value: ` +
+				"must consist of alphanumeric characters, '-', '_' or '.', " +
+				"and must start and end with an alphanumeric character",
+		},
+		{
+			name:     "invalid log paths label",
+			settings: Settings{LogPathsLabel: "logging.example.com/"},
+			expectedError: `log_paths_label: "logging.example.com/" is not a valid label key: ` +
+				"name part must be non-empty",
+		},
+		{
+			name: "log paths label also in additional labels",
+			settings: Settings{
+				AdditionalLabels: map[string]string{"logging": "yes"},
+				LogPathsLabel:    "logging",
+			},
+			expectedError: `log_paths_label: "logging" is also set in additional_labels`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.validLabels()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLabelMutation(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		AdditionalLabels:    map[string]string{"logging.example.com/team": "payments"},
+		LogPathsLabel:       "logging.example.com/enabled",
+	}
+	newDeployment := func(env []*corev1.EnvVar, labels map[string]string) appsv1.Deployment {
+		deployment := newTestDeployment("", &corev1.Container{Name: stringPtr("nginx"), Env: env})
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}}
+		deployment.Spec.Template.Metadata.Labels = labels
+		return deployment
+	}
+	logEnv := []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"}}
+
+	tests := []struct {
+		name           string
+		deployment     appsv1.Deployment
+		expectedLabels map[string]string
+	}{
+		{
+			name:       "labels are added next to the existing ones",
+			deployment: newDeployment(logEnv, map[string]string{"app": "nginx"}),
+			expectedLabels: map[string]string{
+				"app":                         "nginx",
+				"logging.example.com/team":    "payments",
+				"logging.example.com/enabled": "true",
+			},
+		},
+		{
+			name:       "stale log paths label is turned off",
+			deployment: newDeployment(nil, map[string]string{"app": "nginx", "logging.example.com/enabled": "true"}),
+			expectedLabels: map[string]string{
+				"app":                         "nginx",
+				"logging.example.com/enabled": "false",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Object: json.RawMessage(mustMarshalJSON(test.deployment)),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(settings)),
+			}
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.MutatedObject == nil {
+				t.Fatalf("Expected mutation, got: %s", mustMarshalJSON(response))
+			}
+
+			var mutated appsv1.Deployment
+			if err = json.Unmarshal(mustMarshalJSON(response.MutatedObject), &mutated); err != nil {
+				t.Fatalf("Cannot parse mutated object: %v", err)
+			}
+			if labels := templateLabels(&mutated); !reflect.DeepEqual(labels, test.expectedLabels) {
+				t.Errorf("Expected labels %v, got %v", test.expectedLabels, labels)
+			}
+			selector := mutated.Spec.Selector.MatchLabels
+			if !reflect.DeepEqual(selector, map[string]string{"app": "nginx"}) {
+				t.Errorf("Expected selector to be left unchanged, got %v", selector)
+			}
+		})
+	}
+}
+
+func TestValidateModeReportsLabelDrift(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		LogPathsLabel:       "logging.example.com/enabled",
+		Mode:                ModeValidate,
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Metadata: &metav1.ObjectMeta{
+					Annotations: map[string]string{"co_elastic_logs_path": "/var/log/app.log"},
+				},
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{{
+						Name: stringPtr("nginx"),
+						Env:  []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"}},
+					}},
+				},
+			},
+		},
+	}
+	req := kubewarden_protocol.ValidationRequest{
+		Request: kubewarden_protocol.KubernetesAdmissionRequest{
+			Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+			Object: json.RawMessage(mustMarshalJSON(deployment)),
+		},
+		Settings: json.RawMessage(mustMarshalJSON(settings)),
+	}
+
+	response, err := validateTest(t, req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `pod template labels do not match the expected values: logging.example.com/enabled="true" (missing)`
+	if response.Accepted || *response.Message != expected {
+		t.Errorf("Expected rejection %q, got: %s", expected, mustMarshalJSON(response))
+	}
+}
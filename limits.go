@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+const (
+	// LimitActionReject 超出上限时拒绝请求，为默认行为.
+	LimitActionReject = "reject"
+	// LimitActionTruncate 超出上限时丢弃排在后面的日志路径.
+	LimitActionTruncate = "truncate"
+)
+
+// validLimits 校验日志路径数量和注解大小上限的配置.
+func (s *Settings) validLimits() error {
+	var errs FieldErrors
+	if s.MaxPaths < 0 {
+		errs.add("max_paths", "cannot be negative")
+	}
+	if s.MaxAnnotationBytes < 0 {
+		errs.add("max_annotation_bytes", "cannot be negative")
+	}
+	switch s.OnLimitExceeded {
+	case "", LimitActionReject, LimitActionTruncate:
+	default:
+		errs.add("on_limit_exceeded", "must be %s or %s, got %q",
+			LimitActionReject, LimitActionTruncate, s.OnLimitExceeded)
+	}
+	return errs.err()
+}
+
+func (s *Settings) truncateOnLimit() bool {
+	return s.OnLimitExceeded == LimitActionTruncate
+}
+
+// limitLogPaths 按 max_paths 截断日志路径，reject 模式下超出上限时返回错误.
+func (s *Settings) limitLogPaths(container *corev1.Container, logPaths []string) ([]string, error) {
+	if s.MaxPaths == 0 || len(logPaths) <= s.MaxPaths {
+		return logPaths, nil
+	}
+	if s.truncateOnLimit() {
+		return logPaths[:s.MaxPaths], nil
+	}
+
+	containerName := ""
+	if container.Name != nil {
+		containerName = *container.Name
+	}
+	return nil, fmt.Errorf("container %q declares %d %s log paths, more than max_paths %d",
+		containerName, len(logPaths), s.EnvKey, s.MaxPaths)
+}
+
+// checkAnnotationBytes 检查写入 generated 后 Pod 模板注解的总大小是否超出 max_annotation_bytes.
+// 大小按 API Server 的方式计算，即所有键和值的字节数之和.
+func (s *Settings) checkAnnotationBytes(existing, generated map[string]string) error {
+	if s.MaxAnnotationBytes == 0 {
+		return nil
+	}
+
+	size := 0
+	for key, value := range existing {
+		if _, overwritten := generated[key]; !overwritten {
+			size += len(key) + len(value)
+		}
+	}
+	for key, value := range generated {
+		size += len(key) + len(value)
+	}
+	if size > s.MaxAnnotationBytes {
+		return fmt.Errorf("pod template annotations would take %d bytes, more than max_annotation_bytes %d",
+			size, s.MaxAnnotationBytes)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+)
+
+func TestValidLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{name: "no limits", settings: Settings{}},
+		{name: "truncate", settings: Settings{MaxPaths: 10, MaxAnnotationBytes: 262144, OnLimitExceeded: "truncate"}},
+		{name: "negative max paths", settings: Settings{MaxPaths: -1}, expectedError: "max_paths: cannot be negative"},
+		{
+			name:          "negative max annotation bytes",
+			settings:      Settings{MaxAnnotationBytes: -1},
+			expectedError: "max_annotation_bytes: cannot be negative",
+		},
+		{
+			name:          "unknown action",
+			settings:      Settings{OnLimitExceeded: "drop"},
+			expectedError: `on_limit_exceeded: must be reject or truncate, got "drop"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.validLimits()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestPlanLogAnnotationsLimits(t *testing.T) {
+	newDeployment := func(existing map[string]string) *appsv1.Deployment {
+		deployment := newTestDeployment("",
+			envContainer("my-container", "LOG", "/var/log/a.log", "/var/log/b.log", "/var/log/c.log"))
+		deployment.Spec.Template.Metadata.Annotations = existing
+		return &deployment
+	}
+	base := Settings{EnvKey: "LOG", AnnotationBase: "path", AnnotationExtFormat: "path_%d"}
+
+	tests := []struct {
+		name          string
+		existing      map[string]string
+		modify        func(settings *Settings)
+		expectedKeys  []string
+		expectedError string
+	}{
+		{
+			name:         "within limits",
+			modify:       func(settings *Settings) { settings.MaxPaths = 3 },
+			expectedKeys: []string{"path", "path_1", "path_2"},
+		},
+		{
+			name:          "too many paths are rejected",
+			modify:        func(settings *Settings) { settings.MaxPaths = 2 },
+			expectedError: `container "my-container" declares 3 LOG log paths, more than max_paths 2`,
+		},
+		{
+			name: "too many paths are truncated",
+			modify: func(settings *Settings) {
+				settings.MaxPaths = 2
+				settings.OnLimitExceeded = LimitActionTruncate
+			},
+			expectedKeys: []string{"path", "path_1"},
+		},
+		{
+			name:     "oversized annotations are rejected",
+			existing: map[string]string{"existing": strings.Repeat("x", 40)},
+			modify:   func(settings *Settings) { settings.MaxAnnotationBytes = 90 },
+			expectedError: "pod template annotations would take 106 bytes, " +
+				"more than max_annotation_bytes 90",
+		},
+		{
+			name:     "oversized annotations are truncated",
+			existing: map[string]string{"existing": strings.Repeat("x", 40)},
+			modify: func(settings *Settings) {
+				settings.MaxAnnotationBytes = 90
+				settings.OnLimitExceeded = LimitActionTruncate
+			},
+			expectedKeys: []string{"path", "path_1"},
+		},
+		{
+			name:     "truncation cannot make room",
+			existing: map[string]string{"existing": strings.Repeat("x", 100)},
+			modify: func(settings *Settings) {
+				settings.MaxAnnotationBytes = 90
+				settings.OnLimitExceeded = LimitActionTruncate
+			},
+			expectedError: "pod template annotations would take 108 bytes, more than max_annotation_bytes 90",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := base
+			test.modify(&settings)
+
+			plan, err := planLogAnnotations(newDeployment(test.existing), settings)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(plan.annotations) != len(test.expectedKeys) {
+				t.Errorf("Expected annotations %v, got %v", test.expectedKeys, plan.annotations)
+			}
+			for _, key := range test.expectedKeys {
+				if _, ok := plan.annotations[key]; !ok {
+					t.Errorf("Expected annotation %s, got %v", key, plan.annotations)
+				}
+			}
+			if len(plan.logPaths) != len(test.expectedKeys) {
+				t.Errorf("Expected %d log paths, got %v", len(test.expectedKeys), plan.logPaths)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	onelog "github.com/francoispqt/onelog"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
 	wapc "github.com/wapc/wapc-guest-tinygo"
 )
 
@@ -15,6 +16,8 @@ var (
 		&logWriter,
 		onelog.ALL, // shortcut for onelog.DEBUG|onelog.INFO|onelog.WARN|onelog.ERROR|onelog.FATAL
 	)
+	// host 用于调用宿主机能力，单元测试中替换其 Client
+	host = capabilities.NewHost()
 )
 
 func main() {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+)
+
+const (
+	defaultSkipMarker  = "env-to-annotation.example.com/skip"
+	defaultOptInMarker = "env-to-annotation.example.com/enabled"
+)
+
+// WorkloadMarkers 定义了工作负载上用于退出或加入策略的注解或标签.
+type WorkloadMarkers struct {
+	// Skip 退出标记的键，取值为 "true" 时不处理该工作负载，默认 env-to-annotation.example.com/skip
+	Skip string `json:"skip,omitempty"`
+	// OptIn 加入标记的键，默认 env-to-annotation.example.com/enabled
+	OptIn string `json:"opt_in,omitempty"`
+	// RequireOptIn 是否只处理带有取值为 "true" 的加入标记的工作负载
+	RequireOptIn bool `json:"require_opt_in,omitempty"`
+}
+
+// Valid 校验标记键.
+func (m *WorkloadMarkers) Valid() error {
+	var errs FieldErrors
+	if err := validateQualifiedName(m.skipKey()); err != nil {
+		errs.add("markers.skip", "%q is not a valid annotation or label key: %v", m.skipKey(), err)
+	}
+	if err := validateQualifiedName(m.optInKey()); err != nil {
+		errs.add("markers.opt_in", "%q is not a valid annotation or label key: %v", m.optInKey(), err)
+	}
+	if m.skipKey() == m.optInKey() {
+		errs.add("markers.opt_in", "must be different from markers.skip")
+	}
+	return errs.err()
+}
+
+func (m *WorkloadMarkers) skipKey() string {
+	if m.Skip == "" {
+		return defaultSkipMarker
+	}
+	return m.Skip
+}
+
+func (m *WorkloadMarkers) optInKey() string {
+	if m.OptIn == "" {
+		return defaultOptInMarker
+	}
+	return m.OptIn
+}
+
+// skipReason 返回不处理该 Deployment 的原因，需要处理时返回空字符串.
+// 退出标记优先于加入标记.
+func (m *WorkloadMarkers) skipReason(deployment *appsv1.Deployment) string {
+	if hasMarker(deployment, m.skipKey()) {
+		return fmt.Sprintf("workload opted out with %s", m.skipKey())
+	}
+	if m.RequireOptIn && !hasMarker(deployment, m.optInKey()) {
+		return fmt.Sprintf("workload has not opted in with %s", m.optInKey())
+	}
+	return ""
+}
+
+// hasMarker 判断 Deployment 的注解或标签中 key 的取值是否为 true.
+func hasMarker(deployment *appsv1.Deployment, key string) bool {
+	if deployment.Metadata == nil {
+		return false
+	}
+	for _, values := range []map[string]string{deployment.Metadata.Annotations, deployment.Metadata.Labels} {
+		if value, ok := values[key]; ok {
+			if enabled, err := strconv.ParseBool(value); err == nil && enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
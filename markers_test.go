@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestWorkloadMarkersValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		markers       WorkloadMarkers
+		expectedError string
+	}{
+		{name: "defaults", markers: WorkloadMarkers{}},
+		{name: "custom keys", markers: WorkloadMarkers{Skip: "logging/skip", OptIn: "logging/enabled"}},
+		{
+			name:    "invalid key",
+			markers: WorkloadMarkers{Skip: "logging skip"},
+			expectedError: `markers.skip: "logging skip" is not a valid annotation or label key: name part must ` +
+				"consist of alphanumeric characters, '-', '_' or '.', " +
+				"and must start and end with an alphanumeric character",
+		},
+		{
+			name:          "same key twice",
+			markers:       WorkloadMarkers{Skip: "logging", OptIn: "logging"},
+			expectedError: "markers.opt_in: must be different from markers.skip",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.markers.Valid()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestWorkloadMarkers(t *testing.T) {
+	newDeployment := func(annotations, labels map[string]string) appsv1.Deployment {
+		deployment := newTestDeployment("app", envContainer("app", "vestack_varlog", "/var/log/app.log"))
+		deployment.Metadata.Annotations = annotations
+		deployment.Metadata.Labels = labels
+		return deployment
+	}
+	skip := map[string]string{"env-to-annotation.example.com/skip": "true"}
+	optIn := map[string]string{"env-to-annotation.example.com/enabled": "true"}
+
+	tests := []struct {
+		name         string
+		markers      WorkloadMarkers
+		deployment   appsv1.Deployment
+		shouldMutate bool
+	}{
+		{name: "no markers", deployment: newDeployment(nil, nil), shouldMutate: true},
+		{name: "skip annotation", deployment: newDeployment(skip, nil)},
+		{name: "skip label", deployment: newDeployment(nil, skip)},
+		{
+			name:         "skip set to false",
+			deployment:   newDeployment(map[string]string{"env-to-annotation.example.com/skip": "false"}, nil),
+			shouldMutate: true,
+		},
+		{
+			name:       "custom skip key",
+			markers:    WorkloadMarkers{Skip: "logging.example.com/self-shipping"},
+			deployment: newDeployment(map[string]string{"logging.example.com/self-shipping": "true"}, nil),
+		},
+		{
+			name:       "opt-in required but missing",
+			markers:    WorkloadMarkers{RequireOptIn: true},
+			deployment: newDeployment(nil, nil),
+		},
+		{
+			name:         "opt-in label",
+			markers:      WorkloadMarkers{RequireOptIn: true},
+			deployment:   newDeployment(nil, optIn),
+			shouldMutate: true,
+		},
+		{
+			name:       "skip wins over opt-in",
+			markers:    WorkloadMarkers{RequireOptIn: true},
+			deployment: newDeployment(skip, optIn),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{
+				EnvKey:              "vestack_varlog",
+				AnnotationBase:      "co_elastic_logs_path",
+				AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+				Markers:             test.markers,
+			}
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Object: json.RawMessage(mustMarshalJSON(test.deployment)),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(settings)),
+			}
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if test.shouldMutate {
+				assertMutation(t, response, map[string]string{"co_elastic_logs_path": "/var/log/app.log"})
+			} else {
+				assertNoMutation(t, response)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	dnsLabelMaxLength      = 63
+	dnsSubdomainMaxLength  = 253
+	qualifiedNameMaxLength = 63
+	labelValueMaxLength    = 63
+)
+
+// 正则表达式只在初始化时编译一次，校验会在每个准入请求中执行.
+//
+//nolint:gochecknoglobals // 预编译的正则表达式只读.
+var (
+	qualifiedNamePattern = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	dnsLabelPattern      = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dnsSubdomainPattern  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// identifierPattern 是 Filebeat processor 和 OpenTelemetry operator 的名称规则
+	identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// validateQualifiedName 按 Kubernetes 限定名规则校验注解或标签键，即可选的 DNS 子域名前缀加 "/" 和名称.
+func validateQualifiedName(key string) error {
+	name := key
+	parts := strings.Split(key, "/")
+	switch len(parts) {
+	case 1:
+	case 2: //nolint:mnd // 前缀和名称两部分
+		prefix := parts[0]
+		name = parts[1]
+		if prefix == "" {
+			return errors.New("prefix part must be non-empty")
+		}
+		if !isDNSSubdomain(prefix) {
+			return fmt.Errorf("prefix part must be a lowercase RFC 1123 subdomain of at most %d characters",
+				dnsSubdomainMaxLength)
+		}
+	default:
+		return errors.New("must be a name with an optional DNS subdomain prefix separated by a single '/'")
+	}
+
+	if name == "" {
+		return errors.New("name part must be non-empty")
+	}
+	if len(name) > qualifiedNameMaxLength {
+		return fmt.Errorf("name part must be no more than %d characters", qualifiedNameMaxLength)
+	}
+	if !qualifiedNamePattern.MatchString(name) {
+		return errors.New("name part must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// isDNSLabel 判断 s 是否满足 RFC 1123 DNS label 规则.
+func isDNSLabel(s string) bool {
+	if s == "" || len(s) > dnsLabelMaxLength {
+		return false
+	}
+	return dnsLabelPattern.MatchString(s)
+}
+
+// isDNSSubdomain 判断 s 是否满足 RFC 1123 DNS 子域名规则.
+func isDNSSubdomain(s string) bool {
+	if s == "" || len(s) > dnsSubdomainMaxLength {
+		return false
+	}
+	return dnsSubdomainPattern.MatchString(s)
+}
+
+// validateLabelValue 按 Kubernetes 规则校验标签值：可以为空，否则以字母数字开头和结尾.
+func validateLabelValue(value string) error {
+	if len(value) > labelValueMaxLength {
+		return fmt.Errorf("must be no more than %d characters", labelValueMaxLength)
+	}
+	if value != "" && !qualifiedNamePattern.MatchString(value) {
+		return errors.New("must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQualifiedName(t *testing.T) {
+	tests := []struct {
+		key         string
+		expectedErr string
+	}{
+		{key: "co_elastic_logs_path"},
+		{key: "co.elastic.logs/path"},
+		{key: "co.elastic.logs/multiline.pattern"},
+		{key: "example.com/Key_1"},
+		{key: "co.elastic.logs/path/extra", expectedErr: "must be a name with an optional DNS subdomain prefix " +
+			"separated by a single '/'"},
+		{key: "/path", expectedErr: "prefix part must be non-empty"},
+		{key: "Example.COM/path", expectedErr: "prefix part must be a lowercase RFC 1123 subdomain " +
+			"of at most 253 characters"},
+		{key: "example.com/", expectedErr: "name part must be non-empty"},
+		{key: strings.Repeat("a", 64), expectedErr: "name part must be no more than 63 characters"},
+		{key: "-path", expectedErr: "name part must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character"},
+		{key: "log path", expectedErr: "name part must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			err := validateQualifiedName(test.key)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("Expected error %q, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateLabelValue(t *testing.T) {
+	tests := []struct {
+		value       string
+		expectedErr string
+	}{
+		{value: ""},
+		{value: "true"},
+		{value: "payments.v2_Team-1"},
+		{value: strings.Repeat("a", 64), expectedErr: "must be no more than 63 characters"},
+		{value: "-payments", expectedErr: "must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character"},
+		{value: "team/payments", expectedErr: "must consist of alphanumeric characters, '-', '_' or '.', " +
+			"and must start and end with an alphanumeric character"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			err := validateLabelValue(test.value)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("Expected error %q, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "path"
+
+// NamespaceSettings 定义了策略生效的命名空间，支持精确名称和 glob 模式.
+type NamespaceSettings struct {
+	// Include 生效的命名空间，为空时对所有命名空间生效
+	Include []string `json:"include,omitempty"`
+	// Exclude 不生效的命名空间，优先于 Include
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Valid 校验命名空间模式.
+func (n *NamespaceSettings) Valid() error {
+	var errs FieldErrors
+	validGlobPatterns(&errs, "namespaces.include", n.Include)
+	validGlobPatterns(&errs, "namespaces.exclude", n.Exclude)
+	return errs.err()
+}
+
+// applies 判断策略是否对 namespace 生效，同时匹配两个列表时以 Exclude 为准.
+func (n *NamespaceSettings) applies(namespace string) bool {
+	if matchesAnyNamespace(namespace, n.Exclude) {
+		return false
+	}
+	return len(n.Include) == 0 || matchesAnyNamespace(namespace, n.Include)
+}
+
+// validGlobPatterns 校验 field 列表中的每个 glob 模式.
+func validGlobPatterns(errs *FieldErrors, field string, patterns []string) {
+	for i, pattern := range patterns {
+		if pattern == "" {
+			errs.add(fieldIndex(field, i), "cannot be empty")
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			errs.add(fieldIndex(field, i), "%q is not a valid glob pattern: %v", pattern, err)
+		}
+	}
+}
+
+func matchesAnyNamespace(namespace string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestNamespaceSettingsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		namespaces    NamespaceSettings
+		expectedError string
+	}{
+		{name: "no namespaces", namespaces: NamespaceSettings{}},
+		{
+			name:       "names and globs",
+			namespaces: NamespaceSettings{Include: []string{"team-*"}, Exclude: []string{"kube-system", "*-sandbox"}},
+		},
+		{
+			name:          "empty pattern",
+			namespaces:    NamespaceSettings{Include: []string{""}},
+			expectedError: "namespaces.include[0]: cannot be empty",
+		},
+		{
+			name:          "malformed glob",
+			namespaces:    NamespaceSettings{Exclude: []string{"default", "team-[a"}},
+			expectedError: `namespaces.exclude[1]: "team-[a" is not a valid glob pattern: syntax error in pattern`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.namespaces.Valid()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNamespaceSettingsApplies(t *testing.T) {
+	namespaces := NamespaceSettings{
+		Include: []string{"team-*", "default"},
+		Exclude: []string{"kube-system", "monitoring-*", "team-*-sandbox"},
+	}
+	tests := []struct {
+		namespace string
+		expected  bool
+	}{
+		{namespace: "default", expected: true},
+		{namespace: "team-payments", expected: true},
+		{namespace: "team-payments-sandbox", expected: false},
+		{namespace: "kube-system", expected: false},
+		{namespace: "monitoring-prometheus", expected: false},
+		{namespace: "search", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.namespace, func(t *testing.T) {
+			if applies := namespaces.applies(test.namespace); applies != test.expected {
+				t.Errorf("Expected applies=%v, got %v", test.expected, applies)
+			}
+		})
+	}
+
+	excludeOnly := NamespaceSettings{Exclude: []string{"kube-*"}}
+	if !excludeOnly.applies("default") || excludeOnly.applies("kube-public") {
+		t.Errorf("Expected an empty include list to match every namespace that is not excluded")
+	}
+}
+
+func TestExcludedNamespaceIsNotUnmarshalled(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Namespaces:          NamespaceSettings{Exclude: []string{"kube-system"}},
+	}
+	req := kubewarden_protocol.ValidationRequest{
+		Request: kubewarden_protocol.KubernetesAdmissionRequest{
+			Kind:      kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+			Namespace: "kube-system",
+			// 对象无法反序列化，被排除的命名空间仍然应当直接放行
+			Object: json.RawMessage(`"not a deployment"`),
+		},
+		Settings: json.RawMessage(mustMarshalJSON(settings)),
+	}
+
+	response, err := validateTest(t, req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertNoMutation(t, response)
+}
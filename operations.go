@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	operationCreate = "CREATE"
+	operationUpdate = "UPDATE"
+
+	subResourceScale  = "scale"
+	subResourceStatus = "status"
+)
+
+// validOperations 校验 operations，只允许 CREATE 和 UPDATE 且不能重复.
+func (s *Settings) validOperations() error {
+	var errs FieldErrors
+	for i, operation := range s.Operations {
+		if operation != operationCreate && operation != operationUpdate {
+			errs.add(fieldIndex("operations", i), "must be %s or %s, got %q",
+				operationCreate, operationUpdate, operation)
+		} else if slices.Contains(s.Operations[:i], operation) {
+			errs.add(fieldIndex("operations", i), "%q is listed more than once", operation)
+		}
+	}
+	return errs.err()
+}
+
+// handlesOperation 判断策略是否处理该操作，未设置 operations 时处理所有请求.
+func (s *Settings) handlesOperation(operation string) bool {
+	return len(s.Operations) == 0 || slices.Contains(s.Operations, operation)
+}
+
+// isIgnoredSubResource 判断请求是否针对 scale 或 status 子资源，这些请求不会修改 Pod 模板.
+func isIgnoredSubResource(subResource string) bool {
+	return subResource == subResourceScale || subResource == subResourceStatus
+}
+
+// envUnchanged 判断 UPDATE 请求中被选中容器的 env_key 相关字段是否与 OldObject 相同.
+// 不是 UPDATE 或缺少 OldObject 时无法比较，返回 false.
+func envUnchanged(
+	req kubewarden_protocol.KubernetesAdmissionRequest,
+	deployment *appsv1.Deployment,
+	settings Settings,
+) (bool, error) {
+	if req.Operation != operationUpdate || isEmptyObject(req.OldObject) {
+		return false, nil
+	}
+	var oldDeployment appsv1.Deployment
+	if err := json.Unmarshal(req.OldObject, &oldDeployment); err != nil {
+		return false, fmt.Errorf("cannot unmarshal old deployment: %w", err)
+	}
+	return reflect.DeepEqual(envFingerprint(&oldDeployment, settings), envFingerprint(deployment, settings)), nil
+}
+
+// containerEnv 是被选中容器中决定注解内容的字段.
+type containerEnv struct {
+	name   string
+	image  string
+	values []string
+}
+
+// envFingerprint 按顺序收集被选中容器的名称、镜像和 env_key 的取值.
+// 镜像参与比较，因为按镜像选择容器时镜像变化会改变被选中的容器.
+func envFingerprint(deployment *appsv1.Deployment, settings Settings) []containerEnv {
+	var fingerprint []containerEnv
+	for _, container := range settings.Containers.selectContainers(deployment) {
+		entry := containerEnv{image: container.Image, values: envValues(container, settings.EnvKey)}
+		if container.Name != nil {
+			entry.name = *container.Name
+		}
+		fingerprint = append(fingerprint, entry)
+	}
+	return fingerprint
+}
+
+// envValues 返回容器中名为 envKey 的环境变量的取值，valueFrom 引用记为其 JSON 形式.
+func envValues(container *corev1.Container, envKey string) []string {
+	var values []string
+	for _, env := range container.Env {
+		if env == nil || env.Name == nil || *env.Name != envKey {
+			continue
+		}
+		if env.ValueFrom != nil {
+			valueFrom, _ := json.Marshal(env.ValueFrom)
+			values = append(values, "valueFrom:"+string(valueFrom))
+			continue
+		}
+		values = append(values, env.Value)
+	}
+	return values
+}
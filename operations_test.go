@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestValidOperations(t *testing.T) {
+	tests := []struct {
+		name          string
+		operations    []string
+		expectedError string
+	}{
+		{name: "default", operations: nil},
+		{name: "create only", operations: []string{"CREATE"}},
+		{name: "create and update", operations: []string{"CREATE", "UPDATE"}},
+		{
+			name:          "unsupported operation",
+			operations:    []string{"CREATE", "DELETE"},
+			expectedError: `operations[1]: must be CREATE or UPDATE, got "DELETE"`,
+		},
+		{
+			name:          "lowercase operation",
+			operations:    []string{"create"},
+			expectedError: `operations[0]: must be CREATE or UPDATE, got "create"`,
+		},
+		{
+			name:          "duplicate operation",
+			operations:    []string{"UPDATE", "UPDATE"},
+			expectedError: `operations[1]: "UPDATE" is listed more than once`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Operations: test.operations}
+			err := settings.validOperations()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestOperationAwareMutation(t *testing.T) {
+	newDeployment := func(image string, logPaths ...string) appsv1.Deployment {
+		container := envContainer("my-container", "vestack_varlog", logPaths...)
+		container.Image = image
+		container.Env = append(container.Env, &corev1.EnvVar{Name: stringPtr("OTHER"), Value: image})
+		return newTestDeployment("app", container)
+	}
+	current := newDeployment("app:2.0", "/var/log/app.log")
+	annotated := newDeployment("app:2.0", "/var/log/app.log")
+	annotated.Spec.Template.Metadata.Annotations = map[string]string{"co_elastic_logs_path": "/var/log/app.log"}
+
+	tests := []struct {
+		name         string
+		operations   []string
+		operation    string
+		subResource  string
+		object       appsv1.Deployment
+		oldObject    interface{}
+		shouldMutate bool
+	}{
+		{name: "create is mutated by default", operation: "CREATE", shouldMutate: true},
+		{name: "update without old object is mutated", operation: "UPDATE", shouldMutate: true},
+		{
+			name:         "update changing the log paths is mutated",
+			operation:    "UPDATE",
+			oldObject:    newDeployment("app:2.0", "/var/log/old.log"),
+			shouldMutate: true,
+		},
+		{
+			name:         "update adding env_key is mutated",
+			operation:    "UPDATE",
+			oldObject:    newDeployment("app:2.0"),
+			shouldMutate: true,
+		},
+		{
+			name:         "update changing the image is mutated",
+			operation:    "UPDATE",
+			oldObject:    newDeployment("app:1.0", "/var/log/app.log"),
+			shouldMutate: true,
+		},
+		{
+			name:      "update leaving env_key unchanged is accepted as is",
+			operation: "UPDATE",
+			object:    annotated,
+			oldObject: func() appsv1.Deployment {
+				old := newDeployment("app:2.0", "/var/log/app.log")
+				old.Spec.Template.Spec.Containers[0].Env[1].Value = "unrelated"
+				return old
+			}(),
+		},
+		{
+			// server-side apply 或 kubectl replace 会丢弃清单中没有的注解
+			name:         "update leaving env_key unchanged but dropping the annotations is mutated",
+			operation:    "UPDATE",
+			object:       current,
+			oldObject:    annotated,
+			shouldMutate: true,
+		},
+		{name: "update is skipped when only create is handled", operations: []string{"CREATE"}, operation: "UPDATE"},
+		{
+			name:         "create is mutated when only create is handled",
+			operations:   []string{"CREATE"},
+			operation:    "CREATE",
+			shouldMutate: true,
+		},
+		{name: "scale subresource is skipped", operation: "UPDATE", subResource: "scale"},
+		{name: "status subresource is skipped", operation: "UPDATE", subResource: "status"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{
+				EnvKey:              "vestack_varlog",
+				AnnotationBase:      "co_elastic_logs_path",
+				AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+				Operations:          test.operations,
+			}
+			object := test.object
+			if object.Spec == nil {
+				object = current
+			}
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:        kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Operation:   test.operation,
+					SubResource: test.subResource,
+					Object:      json.RawMessage(mustMarshalJSON(object)),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(settings)),
+			}
+			if test.oldObject != nil {
+				req.Request.OldObject = json.RawMessage(mustMarshalJSON(test.oldObject))
+			}
+
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if test.shouldMutate {
+				assertMutation(t, response, map[string]string{"co_elastic_logs_path": "/var/log/app.log"})
+			} else {
+				assertNoMutation(t, response)
+			}
+		})
+	}
+}
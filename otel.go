@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+const otelDiscoveryPrefix = "io.opentelemetry.discovery.logs"
+
+// OTelDiscoveryOptions 定义了 otel-discovery profile 的选项.
+type OTelDiscoveryOptions struct {
+	// PerContainer 是否输出 io.opentelemetry.discovery.logs.<container>/* 形式的容器级注解
+	PerContainer bool `json:"per_container,omitempty"`
+	// Enabled 是否采集，默认 true；为 false 时只输出 enabled 注解
+	Enabled *bool `json:"enabled,omitempty"`
+	// StartAt filelog receiver 的 start_at，beginning 或 end
+	StartAt string `json:"start_at,omitempty"`
+	// IncludeFilePath filelog receiver 的 include_file_path
+	IncludeFilePath *bool `json:"include_file_path,omitempty"`
+	// IncludeFileName filelog receiver 的 include_file_name
+	IncludeFileName *bool `json:"include_file_name,omitempty"`
+	// Operators filelog receiver 的 operators，每一项必须包含 type
+	Operators []map[string]interface{} `json:"operators,omitempty"`
+}
+
+// Valid 校验 otel-discovery 选项，并检查渲染出的注解键是否合法.
+func (o *OTelDiscoveryOptions) Valid() error {
+	var errs FieldErrors
+	switch o.StartAt {
+	case "", "beginning", "end":
+	default:
+		errs.add("profile_options.start_at", "must be beginning or end, got %q", o.StartAt)
+	}
+	for i, operator := range o.Operators {
+		operatorType, ok := operator["type"].(string)
+		if !ok || !identifierPattern.MatchString(operatorType) {
+			errs.add(fieldIndex("profile_options.operators", i)+".type", "must be an operator name")
+		}
+	}
+
+	for _, key := range sortedKeys(o.render(sampleContainerName, []string{"/var/log/sample.log"})) {
+		if err := validateQualifiedName(key); err != nil {
+			errs.add("profile_options", "render invalid annotation key %q: %v", key, err)
+		}
+	}
+	return errs.err()
+}
+
+// Render 生成 receiver_creator 发现注解，per_container 时每个容器各自一组，否则全部容器的路径写入同一组.
+func (o *OTelDiscoveryOptions) Render(result *DiscoveryResult) (map[string]string, error) {
+	if !o.PerContainer {
+		return renderMerged(result, func(logPaths []string) map[string]string {
+			return o.render("", logPaths)
+		}), nil
+	}
+	return renderContainers(result, func(containerName string, logPaths []string) (map[string]string, error) {
+		return o.render(containerName, logPaths), nil
+	})
+}
+
+// render 生成容器的 receiver_creator 发现注解，config 为 filelog receiver 的 YAML 配置.
+func (o *OTelDiscoveryOptions) render(containerName string, logPaths []string) map[string]string {
+	prefix := otelDiscoveryPrefix + "/"
+	if o.PerContainer {
+		prefix = otelDiscoveryPrefix + "." + containerName + "/"
+	}
+
+	if o.Enabled != nil && !*o.Enabled {
+		return map[string]string{prefix + "enabled": "false"}
+	}
+	return map[string]string{
+		prefix + "enabled": "true",
+		prefix + "config":  o.config(logPaths),
+	}
+}
+
+// config 渲染 filelog receiver 配置，operators 以 JSON 流式写法输出，它同样是合法的 YAML.
+func (o *OTelDiscoveryOptions) config(logPaths []string) string {
+	lines := []string{"include:"}
+	for _, logPath := range logPaths {
+		lines = append(lines, "  - "+yamlScalar(logPath))
+	}
+	if o.StartAt != "" {
+		lines = append(lines, "start_at: "+o.StartAt)
+	}
+	if o.IncludeFilePath != nil {
+		lines = append(lines, "include_file_path: "+strconv.FormatBool(*o.IncludeFilePath))
+	}
+	if o.IncludeFileName != nil {
+		lines = append(lines, "include_file_name: "+strconv.FormatBool(*o.IncludeFileName))
+	}
+	if len(o.Operators) > 0 {
+		operators, err := json.Marshal(o.Operators)
+		if err == nil {
+			lines = append(lines, "operators: "+string(operators))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
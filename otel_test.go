@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOTelDiscoveryOptionsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		expectedError string
+	}{
+		{name: "no options", options: `{}`},
+		{
+			name: "complete options",
+			options: `{"per_container": true, "start_at": "end", "include_file_name": false,
+				"operators": [{"type": "json_parser"}]}`,
+		},
+		{
+			name:          "unknown start at",
+			options:       `{"start_at": "middle"}`,
+			expectedError: `profile_options.start_at: must be beginning or end, got "middle"`,
+		},
+		{
+			name:          "operator without type",
+			options:       `{"operators": [{"parse_from": "body"}]}`,
+			expectedError: "profile_options.operators[0].type: must be an operator name",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{Profile: ProfileOTelDiscovery, ProfileOptions: json.RawMessage(test.options)}
+			err := settings.validProfile()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestOTelDiscoveryRenderDisabled(t *testing.T) {
+	settings := Settings{Profile: ProfileOTelDiscovery, ProfileOptions: json.RawMessage(`{"enabled": false}`)}
+	annotations, err := renderContainer(settings, "app", []string{"/var/log/app.log"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(annotations) != 1 || annotations["io.opentelemetry.discovery.logs/enabled"] != "false" {
+		t.Errorf("Expected only the enabled annotation set to false, got %v", annotations)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+)
+
+const (
+	defaultOverrideAnnotationPrefix = "env-to-annotation.example.com"
+	overrideAdditionalAnnotations   = "additional-annotations"
+	overrideAdditionalLabels        = "additional-labels"
+)
+
+// NamespaceOverrideSettings 定义了从请求所在命名空间的注解中读取的配置覆盖，需要策略以 context aware 方式运行.
+type NamespaceOverrideSettings struct {
+	// Enabled 是否读取命名空间注解
+	Enabled bool `json:"enabled,omitempty"`
+	// AnnotationPrefix 命名空间注解键的前缀，默认 env-to-annotation.example.com
+	AnnotationPrefix string `json:"annotation_prefix,omitempty"`
+}
+
+// Valid 校验命名空间覆盖配置.
+func (o *NamespaceOverrideSettings) Valid() error {
+	var errs FieldErrors
+	if o.AnnotationPrefix != "" && !isDNSSubdomain(o.AnnotationPrefix) {
+		errs.add("namespace_overrides.annotation_prefix", "%q must be a lowercase RFC 1123 subdomain",
+			o.AnnotationPrefix)
+	}
+	return errs.err()
+}
+
+// annotationKey 返回 <annotation_prefix>/<name> 形式的命名空间注解键.
+func (o *NamespaceOverrideSettings) annotationKey(name string) string {
+	prefix := o.AnnotationPrefix
+	if prefix == "" {
+		prefix = defaultOverrideAnnotationPrefix
+	}
+	return prefix + "/" + name
+}
+
+// applyNamespaceOverrides 读取 namespace 的注解并合并到配置上，返回合并后的配置.
+// 命名空间中的同名键覆盖策略配置，值为 null 的键会删除策略配置中的同名键，合并结果按策略配置的规则重新校验.
+func applyNamespaceOverrides(h *capabilities.Host, settings Settings, namespace string) (Settings, error) {
+	overrides := settings.NamespaceOverrides
+	if overrides == nil || !overrides.Enabled || namespace == "" {
+		return settings, nil
+	}
+
+	annotations, err := namespaceAnnotations(h, namespace)
+	if err != nil {
+		return settings, err
+	}
+
+	key := overrides.annotationKey(overrideAdditionalAnnotations)
+	if raw, ok := annotations[key]; ok {
+		var values map[string]interface{}
+		if err = json.Unmarshal([]byte(raw), &values); err != nil {
+			return settings, fmt.Errorf("namespace %q annotation %q must be a JSON object: %w", namespace, key, err)
+		}
+		settings.AdditionalAnnotations = mergeOverrides(settings.AdditionalAnnotations, values)
+		settings.namespaceAnnotationKeys = map[string]bool{}
+		for name, value := range values {
+			if value != nil {
+				settings.namespaceAnnotationKeys[name] = true
+			}
+		}
+	}
+
+	key = overrides.annotationKey(overrideAdditionalLabels)
+	if raw, ok := annotations[key]; ok {
+		var values map[string]*string
+		if err = json.Unmarshal([]byte(raw), &values); err != nil {
+			return settings, fmt.Errorf("namespace %q annotation %q must be a JSON object of strings: %w",
+				namespace, key, err)
+		}
+		settings.AdditionalLabels = mergeLabelOverrides(settings.AdditionalLabels, values)
+	}
+
+	if _, err = settings.Valid(); err != nil {
+		return settings, fmt.Errorf("settings overridden by namespace %q are not valid: %w", namespace, err)
+	}
+	return settings, nil
+}
+
+// checkNamespaceAnnotations 拒绝命名空间注解写入渲染器所用的键，rendered 为渲染器本次输出的注解.
+// 这些键的取值不经过 path_rules 校验，允许覆盖就能让采集器读取任意文件；
+// base-ext 的编号键即使本次没有渲染也会被采集器读取，同样拒绝.
+func (s *Settings) checkNamespaceAnnotations(rendered map[string]string) error {
+	for _, key := range sortedKeys(s.namespaceAnnotationKeys) {
+		if _, ok := rendered[key]; !ok && !s.isBaseExtPathKey(key) {
+			continue
+		}
+		profile := s.Profile
+		if s.usesBaseExt() {
+			profile = ProfileBaseExt
+		}
+		return fmt.Errorf("additional annotation %q from the namespace collides with a key written by the %s profile",
+			key, profile)
+	}
+	return nil
+}
+
+// namespaceAnnotations 通过宿主机能力读取命名空间对象的注解.
+func namespaceAnnotations(h *capabilities.Host, namespace string) (map[string]string, error) {
+	response, err := kubernetes.GetResource(h, kubernetes.GetResourceRequest{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       namespace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read namespace %q: %w", namespace, err)
+	}
+
+	var object corev1.Namespace
+	if err = json.Unmarshal(response, &object); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal namespace %q: %w", namespace, err)
+	}
+	if object.Metadata == nil {
+		return nil, nil
+	}
+	return object.Metadata.Annotations, nil
+}
+
+// mergeOverrides 返回 base 与 overrides 合并后的新 map，值为 nil 的键会被删除.
+func mergeOverrides(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeLabelOverrides 与 mergeOverrides 相同，用于字符串取值的标签.
+func mergeLabelOverrides(base map[string]string, overrides map[string]*string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+	return merged
+}
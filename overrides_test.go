@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// fakeWapcClient 模拟宿主机的 get_resource 能力，按名称返回命名空间对象.
+type fakeWapcClient struct {
+	namespaces map[string]corev1.Namespace
+	calls      int
+}
+
+func (c *fakeWapcClient) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	c.calls++
+	if binding != "kubewarden" || namespace != "kubernetes" || operation != "get_resource" {
+		return nil, errors.New("unexpected host call " + binding + "/" + namespace + "/" + operation)
+	}
+	var req kubernetes.GetResourceRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	object, ok := c.namespaces[req.Name]
+	if req.APIVersion != "v1" || req.Kind != "Namespace" || !ok {
+		return nil, errors.New("resource not found")
+	}
+	return json.Marshal(object)
+}
+
+// useFakeHost 在测试期间用 client 替换全局 host 的 Client.
+func useFakeHost(t *testing.T, client *fakeWapcClient) {
+	t.Helper()
+	previous := host.Client
+	host.Client = client
+	t.Cleanup(func() { host.Client = previous })
+}
+
+func TestNamespaceOverrides(t *testing.T) {
+	newNamespace := func(name string, annotations map[string]string) corev1.Namespace {
+		return corev1.Namespace{Metadata: &metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+	client := &fakeWapcClient{namespaces: map[string]corev1.Namespace{
+		"plain": newNamespace("plain", nil),
+		"payments": newNamespace("payments", map[string]string{
+			"env-to-annotation.example.com/additional-annotations": `{"index": "payments-logs", "team": null}`,
+			"env-to-annotation.example.com/additional-labels":      `{"logging.example.com/team": "payments"}`,
+		}),
+		"broken": newNamespace("broken", map[string]string{
+			"env-to-annotation.example.com/additional-annotations": `["not", "an", "object"]`,
+		}),
+		"invalid": newNamespace("invalid", map[string]string{
+			"env-to-annotation.example.com/additional-labels": `{"team": "payments team"}`,
+		}),
+		"hijack-base": newNamespace("hijack-base", map[string]string{
+			"env-to-annotation.example.com/additional-annotations": `{"co_elastic_logs_path": "/etc/shadow"}`,
+		}),
+		"hijack-ext": newNamespace("hijack-ext", map[string]string{
+			"env-to-annotation.example.com/additional-annotations": `{"co_elastic_logs_path_ext_7": "/etc/shadow"}`,
+		}),
+		"hijack-elastic": newNamespace("hijack-elastic", map[string]string{
+			"env-to-annotation.example.com/additional-annotations": `{"co.elastic.logs/paths": "/etc/shadow"}`,
+		}),
+	}}
+	useFakeHost(t, client)
+
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		AdditionalAnnotations: map[string]interface{}{
+			"index": "default-logs",
+			"team":  "platform",
+		},
+		NamespaceOverrides: &NamespaceOverrideSettings{Enabled: true},
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{{
+						Name: stringPtr("app"),
+						Env:  []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"}},
+					}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		namespace           string
+		profile             string
+		expectedAnnotations map[string]string
+		expectedLabels      map[string]string
+		expectedMessage     string
+	}{
+		{
+			name:      "namespace without overrides keeps the settings",
+			namespace: "plain",
+			expectedAnnotations: map[string]string{
+				"co_elastic_logs_path": "/var/log/app.log",
+				"index":                "default-logs",
+				"team":                 "platform",
+			},
+		},
+		{
+			name:      "namespace values win and null removes a key",
+			namespace: "payments",
+			expectedAnnotations: map[string]string{
+				"co_elastic_logs_path": "/var/log/app.log",
+				"index":                "payments-logs",
+			},
+			expectedLabels: map[string]string{"logging.example.com/team": "payments"},
+		},
+		{
+			name:      "malformed override is rejected",
+			namespace: "broken",
+			expectedMessage: `namespace "broken" annotation "env-to-annotation.example.com/additional-annotations" ` +
+				"must be a JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}",
+		},
+		{
+			name:      "override breaking the settings rules is rejected",
+			namespace: "invalid",
+			expectedMessage: `settings overridden by namespace "invalid" are not valid: additional_labels["team"]: ` +
+				`value "payments team" is not a valid label value: must consist of alphanumeric ` +
+				"characters, '-', '_' or '.', and must start and end with an alphanumeric character",
+		},
+		{
+			name:      "override of the base path key is rejected",
+			namespace: "hijack-base",
+			expectedMessage: `additional annotation "co_elastic_logs_path" from the namespace collides ` +
+				"with a key written by the base-ext profile",
+		},
+		{
+			name:      "override of an unused numbered path key is rejected",
+			namespace: "hijack-ext",
+			expectedMessage: `additional annotation "co_elastic_logs_path_ext_7" from the namespace collides ` +
+				"with a key written by the base-ext profile",
+		},
+		{
+			name:      "override of a key rendered by another profile is rejected",
+			namespace: "hijack-elastic",
+			profile:   ProfileElasticHints,
+			expectedMessage: `additional annotation "co.elastic.logs/paths" from the namespace collides ` +
+				"with a key written by the elastic-hints profile",
+		},
+		{
+			name:            "namespace lookup failure is rejected",
+			namespace:       "missing",
+			expectedMessage: `cannot read namespace "missing": resource not found`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			caseSettings := settings
+			if test.profile != "" {
+				caseSettings.Profile = test.profile
+			}
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:      kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Namespace: test.namespace,
+					Object:    json.RawMessage(mustMarshalJSON(deployment)),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(caseSettings)),
+			}
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if test.expectedMessage != "" {
+				if response.Accepted || *response.Message != test.expectedMessage {
+					t.Errorf("Expected rejection %q, got: %s", test.expectedMessage, mustMarshalJSON(response))
+				}
+				return
+			}
+			assertMutation(t, response, test.expectedAnnotations)
+			var mutated appsv1.Deployment
+			if err = json.Unmarshal(mustMarshalJSON(response.MutatedObject), &mutated); err != nil {
+				t.Fatalf("Cannot parse mutated object: %v", err)
+			}
+			if labels := templateLabels(&mutated); !reflect.DeepEqual(labels, test.expectedLabels) {
+				t.Errorf("Expected labels %v, got %v", test.expectedLabels, labels)
+			}
+		})
+	}
+}
+
+func TestNamespaceOverridesDisabled(t *testing.T) {
+	client := &fakeWapcClient{}
+	useFakeHost(t, client)
+
+	settings := Settings{EnvKey: "vestack_varlog", AnnotationBase: "path", AnnotationExtFormat: "path_%d"}
+	if _, err := applyNamespaceOverrides(&host, settings, "payments"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.calls != 0 {
+		t.Errorf("Expected no host call when namespace_overrides is disabled, got %d", client.calls)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+const (
+	// globChars 是日志路径中可能出现的全部通配符字符.
+	globChars            = "*?[]{}"
+	defaultMaxPathLength = 1024
+)
+
+// PathRules 定义了日志路径的安全校验规则.
+type PathRules struct {
+	// AllowedRoots 允许的日志根目录，为空时不限制
+	AllowedRoots []string `json:"allowed_roots,omitempty"`
+	// AllowedGlobChars 允许出现的通配符字符，未设置时允许 *?[]{} 全部字符，为空字符串时禁止通配符
+	AllowedGlobChars *string `json:"allowed_glob_chars,omitempty"`
+	// MaxLength 单个日志路径的最大长度，默认 1024
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// Valid 校验路径规则本身.
+func (r *PathRules) Valid() error {
+	var errs FieldErrors
+	for i, root := range r.AllowedRoots {
+		if !path.IsAbs(root) {
+			errs.add(fieldIndex("path_rules.allowed_roots", i), "%q must be an absolute path", root)
+		} else if hasDotDotSegment(root) {
+			errs.add(fieldIndex("path_rules.allowed_roots", i), "%q must not contain \"..\" segments", root)
+		}
+	}
+	if r.AllowedGlobChars != nil {
+		for _, c := range *r.AllowedGlobChars {
+			if !strings.ContainsRune(globChars, c) {
+				errs.add("path_rules.allowed_glob_chars", "may only contain %q, got %q", globChars, c)
+			}
+		}
+	}
+	if r.MaxLength < 0 {
+		errs.add("path_rules.max_length", "cannot be negative")
+	}
+	return errs.err()
+}
+
+func (r *PathRules) maxLength() int {
+	if r.MaxLength == 0 {
+		return defaultMaxPathLength
+	}
+	return r.MaxLength
+}
+
+// check 校验单个日志路径，返回不满足的规则.
+func (r *PathRules) check(logPath string) error {
+	if strings.ContainsRune(logPath, 0) {
+		return errors.New("path must not contain NUL bytes")
+	}
+	if len(logPath) > r.maxLength() {
+		return fmt.Errorf("path is longer than %d characters", r.maxLength())
+	}
+	if !path.IsAbs(logPath) {
+		return errors.New("path must be absolute")
+	}
+	if hasDotDotSegment(logPath) {
+		return errors.New(`path must not contain ".." segments`)
+	}
+	if r.AllowedGlobChars != nil {
+		for _, c := range logPath {
+			if strings.ContainsRune(globChars, c) && !strings.ContainsRune(*r.AllowedGlobChars, c) {
+				return fmt.Errorf("glob character %q is not allowed", c)
+			}
+		}
+	}
+	if len(r.AllowedRoots) > 0 && !isUnderAnyRoot(logPath, r.AllowedRoots) {
+		return fmt.Errorf("path is not under any of the allowed roots %v", r.AllowedRoots)
+	}
+	return nil
+}
+
+// checkContainerLogPaths 校验容器中所有 envKey 环境变量的值，错误信息中包含容器、环境变量位置和取值.
+func checkContainerLogPaths(container *corev1.Container, envKey string, rules *PathRules) error {
+	if container == nil {
+		return nil
+	}
+	containerName := ""
+	if container.Name != nil {
+		containerName = *container.Name
+	}
+	for i, env := range container.Env {
+		if env == nil || env.Name == nil || *env.Name != envKey {
+			continue
+		}
+		if err := rules.check(env.Value); err != nil {
+			return fmt.Errorf("container %q env[%d] %s has unsafe log path %q: %w",
+				containerName, i, envKey, env.Value, err)
+		}
+	}
+	return nil
+}
+
+func hasDotDotSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnderAnyRoot(logPath string, roots []string) bool {
+	for _, root := range roots {
+		root = path.Clean(root)
+		if root == "/" || logPath == root || strings.HasPrefix(logPath, root+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestPathRulesValid(t *testing.T) {
+	noGlobs := ""
+	invalidGlobs := "*$"
+	tests := []struct {
+		name    string
+		rules   PathRules
+		wantErr bool
+	}{
+		{name: "empty rules", rules: PathRules{}},
+		{name: "roots and globs", rules: PathRules{AllowedRoots: []string{"/var/log"}, AllowedGlobChars: &noGlobs}},
+		{name: "relative root", rules: PathRules{AllowedRoots: []string{"var/log"}}, wantErr: true},
+		{name: "root with dot-dot", rules: PathRules{AllowedRoots: []string{"/var/log/../etc"}}, wantErr: true},
+		{name: "non glob character", rules: PathRules{AllowedGlobChars: &invalidGlobs}, wantErr: true},
+		{name: "negative max length", rules: PathRules{MaxLength: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rules.Valid()
+			if test.wantErr && err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPathRulesCheck(t *testing.T) {
+	starOnly := "*"
+	rules := PathRules{
+		AllowedRoots:     []string{"/var/log", "/data/logs/"},
+		AllowedGlobChars: &starOnly,
+		MaxLength:        64,
+	}
+	tests := []struct {
+		path        string
+		expectedErr string
+	}{
+		{path: "/var/log/app/info.log"},
+		{path: "/data/logs/*.log"},
+		{path: "/var/log"},
+		{path: "var/log/app.log", expectedErr: "path must be absolute"},
+		{path: "/var/log/../../etc/shadow", expectedErr: `path must not contain ".." segments`},
+		{path: "/var/log/app\x00.log", expectedErr: "path must not contain NUL bytes"},
+		{path: "/var/log/" + strings.Repeat("a", 64), expectedErr: "path is longer than 64 characters"},
+		{path: "/var/log/app-?.log", expectedErr: "glob character '?' is not allowed"},
+		{path: "/var/logs/app.log", expectedErr: "path is not under any of the allowed roots [/var/log /data/logs/]"},
+		{path: "/etc/shadow", expectedErr: "path is not under any of the allowed roots [/var/log /data/logs/]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := rules.check(test.path)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("Expected error %q, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestUnsafeLogPathIsRejected(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("my-container"),
+							Env: []*corev1.EnvVar{
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"},
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/../../etc/shadow"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	req := kubewarden_protocol.ValidationRequest{
+		Request: kubewarden_protocol.KubernetesAdmissionRequest{
+			Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+			Object: json.RawMessage(mustMarshalJSON(deployment)),
+		},
+		Settings: json.RawMessage(mustMarshalJSON(settings)),
+	}
+
+	response, err := validateTest(t, req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Accepted {
+		t.Fatalf("Expected request to be rejected")
+	}
+	expected := `container "my-container" env[1] vestack_varlog has unsafe log path "/var/log/../../etc/shadow": ` +
+		`path must not contain ".." segments`
+	if *response.Message != expected {
+		t.Errorf("Expected message %q, got %q", expected, *response.Message)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// ProfileBaseExt 把日志路径写入 annotation_base 和按 annotation_ext_format 编号的注解，为默认 profile.
+	ProfileBaseExt = "base-ext"
+	// ProfileElasticHints 以 Filebeat hints 注解的形式输出日志路径及采集选项.
+	ProfileElasticHints = "elastic-hints"
+	// ProfileDatadog 以 Datadog Autodiscovery 的 ad.datadoghq.com/<container>.logs 注解输出日志路径.
+	ProfileDatadog = "datadog"
+	// ProfileFluentBit 输出 Fluent Bit kubernetes filter 识别的 fluentbit.io/* 注解.
+	ProfileFluentBit = "fluentbit"
+	// ProfileOTelDiscovery 输出 OpenTelemetry Collector receiver_creator 的 io.opentelemetry.discovery.logs/* 注解.
+	ProfileOTelDiscovery = "otel-discovery"
+)
+
+// usesBaseExt 判断是否使用默认的 base-ext profile.
+func (s *Settings) usesBaseExt() bool {
+	return s.Profile == "" || s.Profile == ProfileBaseExt
+}
+
+// renderer 按 profile 创建渲染器，并把 profile_options 解码为该渲染器的选项，错误为 *FieldError.
+func (s *Settings) renderer() (Renderer, error) {
+	switch s.Profile {
+	case "", ProfileBaseExt:
+		if len(bytes.TrimSpace(s.ProfileOptions)) > 0 {
+			return nil, &FieldError{Field: "profile_options", Message: fmt.Sprintf("not supported by the %s profile, "+
+				"configure annotation_base and annotation_ext_format instead", ProfileBaseExt)}
+		}
+		return &baseExtRenderer{settings: s}, nil
+	case ProfileElasticHints:
+		return decodeRenderer[ElasticHintsOptions](s.ProfileOptions)
+	case ProfileDatadog:
+		return decodeRenderer[DatadogOptions](s.ProfileOptions)
+	case ProfileFluentBit:
+		return decodeRenderer[FluentBitOptions](s.ProfileOptions)
+	case ProfileOTelDiscovery:
+		return decodeRenderer[OTelDiscoveryOptions](s.ProfileOptions)
+	default:
+		return nil, &FieldError{Field: "profile", Message: fmt.Sprintf("must be %s, %s, %s, %s or %s, got %q",
+			ProfileBaseExt, ProfileElasticHints, ProfileDatadog, ProfileFluentBit, ProfileOTelDiscovery, s.Profile)}
+	}
+}
+
+// validProfile 校验 profile 及其 profile_options.
+func (s *Settings) validProfile() error {
+	renderer, err := s.renderer()
+	if err != nil {
+		return err
+	}
+	return renderer.Valid()
+}
+
+// decodeRenderer 把 profile_options 解码为渲染器选项 T，*T 必须实现 Renderer.
+func decodeRenderer[T any, PT interface {
+	*T
+	Renderer
+}](raw json.RawMessage) (Renderer, error) {
+	options, err := decodeProfileOptions[T](raw)
+	if err != nil {
+		return nil, err
+	}
+	return PT(options), nil
+}
+
+// decodeProfileOptions 把 profile_options 解码为指定 profile 的选项，未知字段视为错误.
+func decodeProfileOptions[T any](raw json.RawMessage) (*T, error) {
+	options := new(T)
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return options, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(options); err != nil {
+		return nil, &FieldError{Field: "profile_options", Message: err.Error()}
+	}
+	return options, nil
+}
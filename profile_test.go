@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// goldenCase 是 test_data/golden 下的一个用例：用 settings 处理 request 后，pod 模板上应当恰好是 annotations.
+type goldenCase struct {
+	Request     string            `json:"request"`
+	Settings    json.RawMessage   `json:"settings"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+func TestProfileGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("test_data", "golden", "*.json"))
+	if err != nil {
+		t.Fatalf("Cannot list golden files: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No golden files found")
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			var golden goldenCase
+			if err := json.Unmarshal(mustReadFile(t, file), &golden); err != nil {
+				t.Fatalf("Cannot parse golden file: %v", err)
+			}
+			var request kubewarden_protocol.KubernetesAdmissionRequest
+			requestPath := filepath.Join("test_data", golden.Request)
+			if err := json.Unmarshal(mustReadFile(t, requestPath), &request); err != nil {
+				t.Fatalf("Cannot parse request: %v", err)
+			}
+
+			response, err := validateTest(t, kubewarden_protocol.ValidationRequest{
+				Request:  request,
+				Settings: golden.Settings,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.MutatedObject == nil {
+				t.Fatalf("Expected request to be mutated, got: %s", mustMarshalJSON(response))
+			}
+
+			var deployment appsv1.Deployment
+			if err := json.Unmarshal(mustMarshalJSON(response.MutatedObject), &deployment); err != nil {
+				t.Fatalf("Cannot parse mutated object: %v", err)
+			}
+			annotations := templateAnnotations(&deployment)
+			if !reflect.DeepEqual(annotations, golden.Annotations) {
+				t.Errorf("Annotations differ from %s\nexpected: %s\ngot:      %s",
+					file, mustMarshalJSON(golden.Annotations), mustMarshalJSON(annotations))
+			}
+		})
+	}
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Cannot read %s: %v", name, err)
+	}
+	return data
+}
+
+// renderContainer 用 profile 选择的渲染器渲染单个容器的日志路径.
+func renderContainer(settings Settings, containerName string, logPaths []string) (map[string]string, error) {
+	renderer, err := settings.renderer()
+	if err != nil {
+		return nil, err
+	}
+	return renderer.Render(&DiscoveryResult{
+		Containers: []DiscoveredContainer{{Name: containerName, LogPaths: logPaths}},
+	})
+}
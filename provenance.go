@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// policyVersion 与 metadata.yml 中的 io.kubewarden.policy.version 保持一致，由 TestPolicyVersionMatchesMetadata 检查.
+	policyVersion               = "0.0.1"
+	defaultProvenanceAnnotation = "env-to-annotation.example.com/provenance"
+	digestPrefix                = "sha256:"
+)
+
+// ProvenanceSettings 定义了记录注解来源的 provenance 注解.
+type ProvenanceSettings struct {
+	// Enabled 是否写入 provenance 注解
+	Enabled bool `json:"enabled,omitempty"`
+	// Annotation 注解键，默认 env-to-annotation.example.com/provenance
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// provenance 是 provenance 注解的取值，不包含时间戳，重复准入时取值不变.
+type provenance struct {
+	// Version 策略版本
+	Version string `json:"version"`
+	// Settings 生效配置的摘要，包含命名空间覆盖
+	Settings string `json:"settings"`
+	// Annotations 策略生成的其他注解的摘要
+	Annotations string `json:"annotations"`
+}
+
+// Valid 校验 provenance 注解键，它不能与策略写入的其他注解重名.
+func (p *ProvenanceSettings) Valid(settings *Settings) error {
+	var errs FieldErrors
+	key := p.annotationKey()
+	if err := validateQualifiedName(key); err != nil {
+		errs.add("provenance.annotation", "%q is not a valid annotation key: %v", key, err)
+	}
+	if _, ok := settings.AdditionalAnnotations[key]; ok {
+		errs.add("provenance.annotation", "%q cannot also appear in additional_annotations", key)
+	}
+	if settings.usesBaseExt() && key == settings.AnnotationBase {
+		errs.add("provenance.annotation", "%q cannot be the same as annotation_base", key)
+	}
+	return errs.err()
+}
+
+func (p *ProvenanceSettings) annotationKey() string {
+	if p.Annotation == "" {
+		return defaultProvenanceAnnotation
+	}
+	return p.Annotation
+}
+
+// addProvenance 在启用时把 provenance 注解加入 annotations，没有生成任何注解时不添加.
+func (s *Settings) addProvenance(annotations map[string]string) error {
+	if s.Provenance == nil || !s.Provenance.Enabled || len(annotations) == 0 {
+		return nil
+	}
+	settingsJSON, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("cannot marshal settings for provenance: %w", err)
+	}
+	value, err := json.Marshal(provenance{
+		Version:     policyVersion,
+		Settings:    digest(settingsJSON),
+		Annotations: digest([]byte(canonicalAnnotations(annotations))),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal provenance: %w", err)
+	}
+	annotations[s.Provenance.annotationKey()] = string(value)
+	return nil
+}
+
+// canonicalAnnotations 把注解按键排序后逐行写成 key=value，与 map 的遍历顺序无关.
+func canonicalAnnotations(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key)
+		builder.WriteByte('=')
+		builder.WriteString(annotations[key])
+		builder.WriteByte('\n')
+	}
+	return builder.String()
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvenanceSettingsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{
+			name:     "default annotation",
+			settings: Settings{Provenance: &ProvenanceSettings{Enabled: true}},
+		},
+		{
+			name: "custom annotation",
+			settings: Settings{
+				Provenance: &ProvenanceSettings{Enabled: true, Annotation: "logs.example.com/provenance"},
+			},
+		},
+		{
+			name:     "invalid annotation",
+			settings: Settings{Provenance: &ProvenanceSettings{Annotation: "Logs/provenance"}},
+			expectedError: `provenance.annotation: "Logs/provenance" is not a valid annotation key: ` +
+				"prefix part must be a lowercase RFC 1123 subdomain of at most 253 characters",
+		},
+		{
+			name: "collides with additional_annotations",
+			settings: Settings{
+				AdditionalAnnotations: map[string]interface{}{"env-to-annotation.example.com/provenance": "x"},
+				Provenance:            &ProvenanceSettings{Enabled: true},
+			},
+			expectedError: `provenance.annotation: "env-to-annotation.example.com/provenance" ` +
+				"cannot also appear in additional_annotations",
+		},
+		{
+			name: "collides with annotation_base",
+			settings: Settings{
+				AnnotationBase: "logs.example.com/path",
+				Provenance:     &ProvenanceSettings{Enabled: true, Annotation: "logs.example.com/path"},
+			},
+			expectedError: `provenance.annotation: "logs.example.com/path" cannot be the same as annotation_base`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.Provenance.Valid(&test.settings)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAddProvenance(t *testing.T) {
+	settings := Settings{EnvKey: "vestack_varlog", Provenance: &ProvenanceSettings{Enabled: true}}
+	provenanceOf := func(settings Settings, annotations map[string]string) provenance {
+		t.Helper()
+		if err := settings.addProvenance(annotations); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var value provenance
+		if err := json.Unmarshal([]byte(annotations[defaultProvenanceAnnotation]), &value); err != nil {
+			t.Fatalf("Cannot decode provenance annotation: %v", err)
+		}
+		return value
+	}
+
+	first := provenanceOf(settings, map[string]string{"a": "1", "b": "2"})
+	if first.Version != policyVersion {
+		t.Errorf("Expected version %q, got %q", policyVersion, first.Version)
+	}
+	if again := provenanceOf(settings, map[string]string{"b": "2", "a": "1"}); again != first {
+		t.Errorf("Expected the same provenance for the same input, got %+v and %+v", first, again)
+	}
+	changed := provenanceOf(settings, map[string]string{"a": "1", "b": "3"})
+	if changed.Annotations == first.Annotations {
+		t.Errorf("Expected the annotation digest to change with the annotations")
+	}
+	other := settings
+	other.AdditionalLabels = map[string]string{"team": "payments"}
+	if changed = provenanceOf(other, map[string]string{"a": "1", "b": "2"}); changed.Settings == first.Settings {
+		t.Errorf("Expected the settings digest to change with the settings")
+	}
+
+	empty := map[string]string{}
+	if err := settings.addProvenance(empty); err != nil || len(empty) != 0 {
+		t.Errorf("Expected no provenance without generated annotations, got %v (error %v)", empty, err)
+	}
+	disabled := map[string]string{"a": "1"}
+	if err := (&Settings{Provenance: &ProvenanceSettings{}}).addProvenance(disabled); err != nil || len(disabled) != 1 {
+		t.Errorf("Expected no provenance when disabled, got %v (error %v)", disabled, err)
+	}
+}
+
+func TestProvenanceIsIdempotent(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Provenance:          &ProvenanceSettings{Enabled: true},
+	}
+	deployment := appsv1.Deployment{
+		Metadata: &metav1.ObjectMeta{Name: "app"},
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("my-container"),
+							Env: []*corev1.EnvVar{
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app.log"},
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/error.log"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	plan, err := planLogAnnotations(&deployment, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = applyLogPlan(&deployment, plan, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	written := templateAnnotations(&deployment)[defaultProvenanceAnnotation]
+	if written == "" {
+		t.Fatalf("Expected the provenance annotation to be written, got %v", templateAnnotations(&deployment))
+	}
+
+	if plan, err = planLogAnnotations(&deployment, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plan.annotations[defaultProvenanceAnnotation] != written {
+		t.Errorf("Expected re-admission to keep provenance %q, got %q",
+			written, plan.annotations[defaultProvenanceAnnotation])
+	}
+}
+
+func TestPolicyVersionMatchesMetadata(t *testing.T) {
+	metadata, err := os.ReadFile("metadata.yml")
+	if err != nil {
+		t.Fatalf("Cannot read metadata.yml: %v", err)
+	}
+	const key = "io.kubewarden.policy.version:"
+	for _, line := range strings.Split(string(metadata), "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), key)
+		if !found {
+			continue
+		}
+		version := strings.TrimSpace(value)
+		if unquoted, unquoteErr := strconv.Unquote(version); unquoteErr == nil {
+			version = unquoted
+		}
+		if version != policyVersion {
+			t.Errorf("Expected policyVersion %q to match %s %q in metadata.yml", policyVersion, key, version)
+		}
+		return
+	}
+	t.Fatalf("metadata.yml does not set %s", key)
+}
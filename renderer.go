@@ -0,0 +1,166 @@
+package main
+
+import (
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+// Renderer 把日志发现结果渲染为 Pod 模板注解，每种注解格式对应一个实现.
+type Renderer interface {
+	// Valid 校验渲染器自身的选项，在加载配置时调用.
+	Valid() error
+	// Render 返回发现结果对应的注解.
+	Render(result *DiscoveryResult) (map[string]string, error)
+}
+
+// DiscoveryResult 是从工作负载中收集到的日志发现结果.
+type DiscoveryResult struct {
+	// Workload 工作负载的元数据
+	Workload WorkloadMeta
+	// Containers 声明了日志路径的容器，按 Pod 模板中的顺序排列
+	Containers []DiscoveredContainer
+}
+
+// WorkloadMeta 是渲染器可以使用的工作负载元数据.
+type WorkloadMeta struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// DiscoveredContainer 是一个声明了日志路径的容器.
+type DiscoveredContainer struct {
+	// Name 容器名称
+	Name string
+	// Image 容器镜像
+	Image string
+	// LogPaths 按声明顺序收集的日志路径
+	LogPaths []string
+	// Env 容器中直接取值的环境变量，不包含 valueFrom 引用
+	Env map[string]string
+}
+
+// LogPaths 按容器顺序返回全部日志路径.
+func (r *DiscoveryResult) LogPaths() []string {
+	var logPaths []string
+	for _, container := range r.Containers {
+		logPaths = append(logPaths, container.LogPaths...)
+	}
+	return logPaths
+}
+
+// newDiscoveryResult 根据 Deployment 和被选中容器的日志路径生成发现结果，没有名称或日志路径的容器会被忽略.
+func newDiscoveryResult(deployment *appsv1.Deployment, selected []containerLogPaths) *DiscoveryResult {
+	result := &DiscoveryResult{Workload: WorkloadMeta{Kind: "Deployment"}}
+	if deployment.Metadata != nil {
+		result.Workload.Namespace = deployment.Metadata.Namespace
+		result.Workload.Name = deployment.Metadata.Name
+		result.Workload.Labels = deployment.Metadata.Labels
+		result.Workload.Annotations = deployment.Metadata.Annotations
+	}
+	for _, entry := range selected {
+		if entry.container.Name == nil || len(entry.logPaths) == 0 {
+			continue
+		}
+		result.Containers = append(result.Containers, DiscoveredContainer{
+			Name:     *entry.container.Name,
+			Image:    entry.container.Image,
+			LogPaths: entry.logPaths,
+			Env:      literalEnv(entry.container),
+		})
+	}
+	return result
+}
+
+// literalEnv 返回容器中直接取值的环境变量.
+func literalEnv(container *corev1.Container) map[string]string {
+	env := map[string]string{}
+	for _, envVar := range container.Env {
+		if envVar != nil && envVar.Name != nil && envVar.ValueFrom == nil {
+			env[*envVar.Name] = envVar.Value
+		}
+	}
+	return env
+}
+
+// renderContainers 逐个容器调用 render，并合并渲染出的注解.
+func renderContainers(
+	result *DiscoveryResult,
+	render func(containerName string, logPaths []string) (map[string]string, error),
+) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, container := range result.Containers {
+		rendered, err := render(container.Name, container.LogPaths)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range rendered {
+			annotations[key] = value
+		}
+	}
+	return annotations, nil
+}
+
+// renderMerged 把全部容器的日志路径按容器顺序合并后调用一次 render，用于不区分容器的注解键，
+// 避免后面的容器覆盖前面容器的路径.
+func renderMerged(result *DiscoveryResult, render func(logPaths []string) map[string]string) map[string]string {
+	if len(result.Containers) == 0 {
+		return map[string]string{}
+	}
+	return render(result.LogPaths())
+}
+
+// baseExtRenderer 是默认的 base-ext 渲染器，选项为 annotation_base、annotation_ext_format 及编码和编号配置.
+type baseExtRenderer struct {
+	settings *Settings
+}
+
+// Valid 校验 base-ext 的注解键、编码和编号配置，编码和编号有误时不再校验渲染出的键.
+func (r *baseExtRenderer) Valid() error {
+	if err := r.settings.validPathAnnotations(); err != nil {
+		return err
+	}
+	return r.settings.validBaseExtKeys()
+}
+
+// Render 把全部日志路径写入 annotation_base 和按 annotation_ext_format 编号的注解，
+// 非 numbered 编码时只写入 annotation_base.
+func (r *baseExtRenderer) Render(result *DiscoveryResult) (map[string]string, error) {
+	annotations := map[string]string{}
+	logPaths := result.LogPaths()
+	if len(logPaths) == 0 {
+		return annotations, nil
+	}
+	if !r.settings.isNumbered() {
+		annotations[r.settings.AnnotationBase] = r.settings.encodePaths(logPaths)
+		return annotations, nil
+	}
+	for i, logPath := range logPaths {
+		annotations[r.settings.pathKey(i)] = logPath
+	}
+	return annotations, nil
+}
+
+// validBaseExtKeys 校验 annotation_base 和 annotation_ext_format 渲染出的键是否满足 Kubernetes 限定名规则，
+// annotation_ext_format 使用渲染后的样例键校验.
+func (s *Settings) validBaseExtKeys() error {
+	var errs FieldErrors
+	if s.AnnotationBase != "" {
+		if err := validateQualifiedName(s.AnnotationBase); err != nil {
+			errs.add("annotation_base", "%q is not a valid annotation key: %v", s.AnnotationBase, err)
+		}
+	}
+	if s.isNumbered() {
+		for _, index := range s.sampleExtIndexes() {
+			key := s.extKey(index)
+			if err := validateQualifiedName(key); err != nil {
+				errs.add("annotation_ext_format", "%q renders invalid annotation key %q: %v",
+					s.AnnotationExtFormat, key, err)
+				break
+			}
+		}
+	}
+	return errs.err()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRendererSelection(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{
+			name:     "default profile",
+			settings: Settings{AnnotationBase: "path", AnnotationExtFormat: "path_%d"},
+		},
+		{
+			name:     "explicit base-ext profile",
+			settings: Settings{Profile: ProfileBaseExt, AnnotationBase: "path", AnnotationExtFormat: "path_%d"},
+		},
+		{
+			name: "base-ext profile options",
+			settings: Settings{
+				AnnotationBase:      "path",
+				AnnotationExtFormat: "path_%d",
+				ProfileOptions:      json.RawMessage(`{"annotation_base": "path"}`),
+			},
+			expectedError: "profile_options: not supported by the base-ext profile, " +
+				"configure annotation_base and annotation_ext_format instead",
+		},
+		{
+			name:     "base-ext validates its own keys",
+			settings: Settings{Profile: ProfileBaseExt, AnnotationBase: "-path", AnnotationExtFormat: "path_%d"},
+			expectedError: `annotation_base: "-path" is not a valid annotation key: name part must consist of ` +
+				"alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character",
+		},
+		{
+			name:     "unknown profile",
+			settings: Settings{Profile: "splunk"},
+			expectedError: "profile: must be base-ext, elastic-hints, datadog, fluentbit or otel-discovery, " +
+				`got "splunk"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.validProfile()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewDiscoveryResult(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Metadata: &metav1.ObjectMeta{Name: "app", Namespace: "payments", Labels: map[string]string{"team": "payments"}},
+	}
+	container := &corev1.Container{
+		Name:  stringPtr("app"),
+		Image: "registry.example.com/app:1.0",
+		Env: []*corev1.EnvVar{
+			{Name: stringPtr("LOG"), Value: "/var/log/app.log"},
+			{Name: stringPtr("POD_NAME"), ValueFrom: &corev1.EnvVarSource{}},
+		},
+	}
+
+	selected := []containerLogPaths{{container: container, logPaths: []string{"/var/log/app.log"}}}
+	result := newDiscoveryResult(deployment, selected)
+	expected := &DiscoveryResult{
+		Workload: WorkloadMeta{
+			Kind:      "Deployment",
+			Namespace: "payments",
+			Name:      "app",
+			Labels:    map[string]string{"team": "payments"},
+		},
+		Containers: []DiscoveredContainer{{
+			Name:     "app",
+			Image:    "registry.example.com/app:1.0",
+			LogPaths: []string{"/var/log/app.log"},
+			Env:      map[string]string{"LOG": "/var/log/app.log"},
+		}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	selected[0].logPaths = nil
+	if result = newDiscoveryResult(deployment, selected); len(result.Containers) != 0 {
+		t.Errorf("Expected a container without log paths to be left out, got %+v", result.Containers)
+	}
+}
+
+func TestBaseExtRendererRender(t *testing.T) {
+	settings := Settings{AnnotationBase: "path", AnnotationExtFormat: "path_%d"}
+	renderer, err := settings.renderer()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	annotations, err := renderer.Render(&DiscoveryResult{Containers: []DiscoveredContainer{
+		{Name: "app", LogPaths: []string{"/var/log/app.log"}},
+		{Name: "worker", LogPaths: []string{"/var/log/worker.log"}},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"path": "/var/log/app.log", "path_1": "/var/log/worker.log"}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("Expected annotations %v, got %v", expected, annotations)
+	}
+}
+
+func TestRenderersCombineContainers(t *testing.T) {
+	result := &DiscoveryResult{Containers: []DiscoveredContainer{
+		{Name: "a", LogPaths: []string{"/var/log/a.log"}},
+		{Name: "b", LogPaths: []string{"/var/log/b.log"}},
+	}}
+	tests := []struct {
+		name     string
+		settings Settings
+		expected map[string]string
+	}{
+		{
+			name:     "base-ext",
+			settings: Settings{AnnotationBase: "path", AnnotationExtFormat: "path_%d"},
+			expected: map[string]string{"path": "/var/log/a.log", "path_1": "/var/log/b.log"},
+		},
+		{
+			name:     "elastic-hints",
+			settings: Settings{Profile: ProfileElasticHints},
+			expected: map[string]string{
+				"co.elastic.logs/enabled": "true",
+				"co.elastic.logs/paths":   "/var/log/a.log,/var/log/b.log",
+			},
+		},
+		{
+			name: "elastic-hints per container",
+			settings: Settings{
+				Profile:        ProfileElasticHints,
+				ProfileOptions: json.RawMessage(`{"per_container": true}`),
+			},
+			expected: map[string]string{
+				"co.elastic.logs.a/enabled": "true",
+				"co.elastic.logs.a/paths":   "/var/log/a.log",
+				"co.elastic.logs.b/enabled": "true",
+				"co.elastic.logs.b/paths":   "/var/log/b.log",
+			},
+		},
+		{
+			name:     "datadog",
+			settings: Settings{Profile: ProfileDatadog},
+			expected: map[string]string{
+				"ad.datadoghq.com/a.logs": `[{"type":"file","path":"/var/log/a.log","source":"a","service":"a"}]`,
+				"ad.datadoghq.com/b.logs": `[{"type":"file","path":"/var/log/b.log","source":"b","service":"b"}]`,
+			},
+		},
+		{
+			name: "fluentbit",
+			settings: Settings{
+				Profile:        ProfileFluentBit,
+				ProfileOptions: json.RawMessage(`{"parser": "json", "paths_key": "logging.example.com/paths"}`),
+			},
+			expected: map[string]string{
+				"fluentbit.io/parser":       "json",
+				"logging.example.com/paths": "/var/log/a.log,/var/log/b.log",
+			},
+		},
+		{
+			name:     "otel-discovery",
+			settings: Settings{Profile: ProfileOTelDiscovery},
+			expected: map[string]string{
+				"io.opentelemetry.discovery.logs/enabled": "true",
+				"io.opentelemetry.discovery.logs/config":  "include:\n  - /var/log/a.log\n  - /var/log/b.log",
+			},
+		},
+		{
+			name: "otel-discovery per container",
+			settings: Settings{
+				Profile:        ProfileOTelDiscovery,
+				ProfileOptions: json.RawMessage(`{"per_container": true}`),
+			},
+			expected: map[string]string{
+				"io.opentelemetry.discovery.logs.a/enabled": "true",
+				"io.opentelemetry.discovery.logs.a/config":  "include:\n  - /var/log/a.log",
+				"io.opentelemetry.discovery.logs.b/enabled": "true",
+				"io.opentelemetry.discovery.logs.b/config":  "include:\n  - /var/log/b.log",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			renderer, err := test.settings.renderer()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			annotations, err := renderer.Render(result)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(annotations, test.expected) {
+				t.Errorf("Expected annotations %v, got %v", test.expected, annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"slices"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+)
+
+const (
+	selectorOpIn           = "In"
+	selectorOpNotIn        = "NotIn"
+	selectorOpExists       = "Exists"
+	selectorOpDoesNotExist = "DoesNotExist"
+)
+
+// LabelSelector 是 Kubernetes 风格的标签选择器，按 Deployment 的 metadata.labels 匹配.
+type LabelSelector struct {
+	// MatchLabels 必须全部相等的标签
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+	// MatchExpressions 必须全部满足的表达式
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement 是一条选择器表达式.
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Valid 按 Kubernetes 的规则校验选择器.
+func (s *LabelSelector) Valid() error {
+	var errs FieldErrors
+	for _, key := range sortedKeys(s.MatchLabels) {
+		field := fieldKey("selector.matchLabels", key)
+		if err := validateQualifiedName(key); err != nil {
+			errs.add(field, "key is not a valid label key: %v", err)
+		}
+		if err := validateLabelValue(s.MatchLabels[key]); err != nil {
+			errs.add(field, "value %q is not a valid label value: %v", s.MatchLabels[key], err)
+		}
+	}
+	for i := range s.MatchExpressions {
+		s.MatchExpressions[i].validate(&errs, fieldIndex("selector.matchExpressions", i))
+	}
+	return errs.err()
+}
+
+// validate 校验一条选择器表达式，field 为该表达式的定位.
+func (r *LabelSelectorRequirement) validate(errs *FieldErrors, field string) {
+	if err := validateQualifiedName(r.Key); err != nil {
+		errs.add(field+".key", "%q is not a valid label key: %v", r.Key, err)
+	}
+	switch r.Operator {
+	case selectorOpIn, selectorOpNotIn:
+		if len(r.Values) == 0 {
+			errs.add(field+".values", "must be non-empty for operator %s", r.Operator)
+		}
+	case selectorOpExists, selectorOpDoesNotExist:
+		if len(r.Values) > 0 {
+			errs.add(field+".values", "must be empty for operator %s", r.Operator)
+		}
+	default:
+		errs.add(field+".operator", "must be %s, %s, %s or %s, got %q",
+			selectorOpIn, selectorOpNotIn, selectorOpExists, selectorOpDoesNotExist, r.Operator)
+	}
+	for i, value := range r.Values {
+		if err := validateLabelValue(value); err != nil {
+			errs.add(fieldIndex(field+".values", i), "%q is not a valid label value: %v", value, err)
+		}
+	}
+}
+
+// matchesDeployment 判断 Deployment 的 metadata.labels 是否满足选择器，空选择器匹配所有 Deployment.
+func (s *LabelSelector) matchesDeployment(deployment *appsv1.Deployment) bool {
+	var labels map[string]string
+	if deployment.Metadata != nil {
+		labels = deployment.Metadata.Labels
+	}
+	return s.matches(labels)
+}
+
+// matches 判断标签是否满足选择器.
+func (s *LabelSelector) matches(labels map[string]string) bool {
+	for key, value := range s.MatchLabels {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	for _, requirement := range s.MatchExpressions {
+		if !requirement.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches 按 Kubernetes 的语义匹配表达式，NotIn 在标签不存在时也视为满足.
+func (r *LabelSelectorRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case selectorOpIn:
+		return ok && slices.Contains(r.Values, value)
+	case selectorOpNotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case selectorOpExists:
+		return ok
+	case selectorOpDoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
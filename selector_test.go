@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestLabelSelectorValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		selector      string
+		expectedError string
+	}{
+		{name: "empty selector", selector: `{}`},
+		{
+			name: "complete selector",
+			selector: `{"matchLabels": {"app.kubernetes.io/part-of": "shop"}, "matchExpressions": [
+				{"key": "team", "operator": "In", "values": ["payments", "search"]},
+				{"key": "legacy", "operator": "DoesNotExist"}
+			]}`,
+		},
+		{
+			name:     "invalid match labels value",
+			selector: `{"matchLabels": {"team": "pay ments"}}`,
+			expectedError: `selector.matchLabels["team"]: value "pay ments" is not a valid label value: ` +
+				"must consist of alphanumeric characters, '-', '_' or '.', " +
+				"and must start and end with an alphanumeric character",
+		},
+		{
+			name:     "unknown operator",
+			selector: `{"matchExpressions": [{"key": "team", "operator": "Equals", "values": ["a"]}]}`,
+			expectedError: "selector.matchExpressions[0].operator: must be In, NotIn, Exists or DoesNotExist, " +
+				`got "Equals"`,
+		},
+		{
+			name:          "In without values",
+			selector:      `{"matchExpressions": [{"key": "team", "operator": "In"}]}`,
+			expectedError: "selector.matchExpressions[0].values: must be non-empty for operator In",
+		},
+		{
+			name:          "Exists with values",
+			selector:      `{"matchExpressions": [{"key": "team", "operator": "Exists", "values": ["a"]}]}`,
+			expectedError: "selector.matchExpressions[0].values: must be empty for operator Exists",
+		},
+		{
+			name:     "invalid expression key",
+			selector: `{"matchExpressions": [{"key": "", "operator": "Exists"}]}`,
+			expectedError: `selector.matchExpressions[0].key: "" is not a valid label key: ` +
+				"name part must be non-empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var selector LabelSelector
+			if err := json.Unmarshal([]byte(test.selector), &selector); err != nil {
+				t.Fatalf("Cannot parse selector: %v", err)
+			}
+			err := selector.Valid()
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector LabelSelector
+		labels   map[string]string
+		expected bool
+	}{
+		{name: "empty selector", selector: LabelSelector{}, labels: nil, expected: true},
+		{
+			name:     "match labels",
+			selector: LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+			labels:   map[string]string{"team": "payments", "app": "api"},
+			expected: true,
+		},
+		{
+			name:     "match labels differ",
+			selector: LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+			labels:   map[string]string{"team": "search"},
+		},
+		{
+			name:     "In",
+			selector: requirementSelector("team", selectorOpIn, "payments", "search"),
+			labels:   map[string]string{"team": "search"},
+			expected: true,
+		},
+		{
+			name:     "In without the label",
+			selector: requirementSelector("team", selectorOpIn, "payments"),
+		},
+		{
+			name:     "NotIn",
+			selector: requirementSelector("team", selectorOpNotIn, "payments"),
+			labels:   map[string]string{"team": "payments"},
+		},
+		{
+			name:     "NotIn without the label",
+			selector: requirementSelector("team", selectorOpNotIn, "payments"),
+			expected: true,
+		},
+		{
+			name:     "Exists",
+			selector: requirementSelector("team", selectorOpExists),
+			labels:   map[string]string{"team": ""},
+			expected: true,
+		},
+		{
+			name:     "DoesNotExist",
+			selector: requirementSelector("legacy", selectorOpDoesNotExist),
+			labels:   map[string]string{"legacy": "true"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matched := test.selector.matches(test.labels); matched != test.expected {
+				t.Errorf("Expected match=%v, got %v", test.expected, matched)
+			}
+		})
+	}
+}
+
+func TestSelectorScopesDeployments(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+	}
+	selector := requirementSelector("legacy", selectorOpDoesNotExist)
+	settings.Selector = &selector
+	for _, legacy := range []bool{false, true} {
+		labels := map[string]string{"app": "api"}
+		if legacy {
+			labels["legacy"] = "true"
+		}
+		deployment := appsv1.Deployment{
+			Metadata: &metav1.ObjectMeta{Name: "api", Labels: labels},
+			Spec: &appsv1.DeploymentSpec{
+				Template: &corev1.PodTemplateSpec{
+					Spec: &corev1.PodSpec{
+						Containers: []*corev1.Container{{
+							Name: stringPtr("api"),
+							Env:  []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/api.log"}},
+						}},
+					},
+				},
+			},
+		}
+		req := kubewarden_protocol.ValidationRequest{
+			Request: kubewarden_protocol.KubernetesAdmissionRequest{
+				Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+				Object: json.RawMessage(mustMarshalJSON(deployment)),
+			},
+			Settings: json.RawMessage(mustMarshalJSON(settings)),
+		}
+		response, err := validateTest(t, req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if legacy {
+			assertNoMutation(t, response)
+		} else {
+			assertMutation(t, response, map[string]string{"co_elastic_logs_path": "/var/log/api.log"})
+		}
+	}
+}
+
+func requirementSelector(key, operator string, values ...string) LabelSelector {
+	return LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: key, Operator: operator, Values: values}}}
+}
@@ -4,12 +4,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
+const (
+	// ModeMutate 直接修改 Pod 模板注解，为默认模式.
+	ModeMutate = "mutate"
+	// ModeValidate 只校验 Pod 模板注解，缺失或不一致时拒绝请求.
+	ModeValidate = "validate"
+
+	// maxSampleExtIndex 是未设置 max_paths 时校验 annotation_ext_format 使用的最大样例序号.
+	maxSampleExtIndex = 99
+)
+
 // Settings 定义了策略中的所有可配置项.
 type Settings struct {
 	// EnvKey 容器环境变量名称，用于匹配需要转换的环境变量
@@ -19,8 +28,63 @@ type Settings struct {
 	// AnnotationExtFormat 扩展注解键名格式，用于后续的日志路径
 	// 格式为: co_elastic_logs_path_ext_%d，其中 %d 会被替换为序号 1,2,3...
 	AnnotationExtFormat string `json:"annotation_ext_format"`
+	// Encoding 日志路径的编码方式，可选 numbered(默认)、joined、json-array 或 yaml-list
+	Encoding string `json:"encoding,omitempty"`
+	// Separator joined 编码使用的分隔符，默认为逗号
+	Separator string `json:"separator,omitempty"`
+	// IndexStart 扩展注解键的起始序号，默认 1
+	IndexStart *int `json:"index_start,omitempty"`
+	// IndexPadding 序号的零填充宽度，0 表示沿用 annotation_ext_format 中的写法
+	IndexPadding int `json:"index_padding,omitempty"`
+	// FirstPathKey 第一个日志路径使用的键，可选 base(默认) 或 ext
+	FirstPathKey string `json:"first_path_key,omitempty"`
 	// AdditionalAnnotations 自定义注解键值对
 	AdditionalAnnotations map[string]interface{} `json:"additional_annotations,omitempty"`
+	// AdditionalLabels 声明了 env_key 的 Pod 模板上额外写入的标签
+	AdditionalLabels map[string]string `json:"additional_labels,omitempty"`
+	// LogPathsLabel 标记 Pod 模板是否包含日志路径的标签键，为空时不写入
+	LogPathsLabel string `json:"log_paths_label,omitempty"`
+	// Mode 运行模式，可选 mutate(默认) 或 validate
+	Mode string `json:"mode,omitempty"`
+	// Operations 处理的请求操作，可选 CREATE、UPDATE，未设置时两者都处理
+	Operations []string `json:"operations,omitempty"`
+	// LogLevel 准入决定的日志级别，可选 debug、info(默认)、warn 或 error
+	LogLevel string `json:"log_level,omitempty"`
+	// MaxPaths 单个容器最多转换的日志路径数量，0 表示不限制
+	MaxPaths int `json:"max_paths,omitempty"`
+	// MaxAnnotationBytes 写入后 Pod 模板注解的总字节数上限，0 表示不限制
+	MaxAnnotationBytes int `json:"max_annotation_bytes,omitempty"`
+	// OnLimitExceeded 超出上限时的处理方式，可选 reject(默认) 或 truncate
+	OnLimitExceeded string `json:"on_limit_exceeded,omitempty"`
+	// Namespaces 策略生效的命名空间
+	Namespaces NamespaceSettings `json:"namespaces"`
+	// Containers 读取 env_key 的容器，未设置时只读取第一个容器
+	Containers ContainerSelection `json:"containers"`
+	// Exemptions 不做修改的请求发起者
+	Exemptions RequesterExemptions `json:"exemptions"`
+	// AuditUsers 后台审计扫描的用户名，支持 glob 模式，未设置时为 Kubewarden 默认的审计扫描用户
+	AuditUsers []string `json:"audit_users,omitempty"`
+	// Selector 按 Deployment 的标签选择生效的工作负载，未设置时对所有工作负载生效
+	Selector *LabelSelector `json:"selector,omitempty"`
+	// Markers 工作负载上的退出和加入标记
+	Markers WorkloadMarkers `json:"markers"`
+	// NamespaceOverrides 从命名空间注解读取的配置覆盖
+	NamespaceOverrides *NamespaceOverrideSettings `json:"namespace_overrides,omitempty"`
+	// Provenance 记录注解来源的 provenance 注解
+	Provenance *ProvenanceSettings `json:"provenance,omitempty"`
+	// PathRules 日志路径的安全校验规则
+	PathRules PathRules `json:"path_rules"`
+	// Profile 注解渲染器名称，默认 base-ext，即按 annotation_base/annotation_ext_format 输出
+	Profile string `json:"profile,omitempty"`
+	// ProfileOptions 渲染器专属的结构化选项
+	ProfileOptions json.RawMessage `json:"profile_options,omitempty"`
+	// LogVolume 为未挂载到任何卷上的日志目录自动注入共享卷
+	LogVolume *LogVolumeSettings `json:"log_volume,omitempty"`
+	// Sidecar 向声明了 env_key 的 Pod 模板注入日志采集 sidecar
+	Sidecar *SidecarSettings `json:"sidecar,omitempty"`
+
+	// namespaceAnnotationKeys 由命名空间注解写入 additional_annotations 的键
+	namespaceAnnotationKeys map[string]bool
 }
 
 // NewSettingsFromValidationReq 从 ValidationRequest 中提取设置.
@@ -30,39 +94,113 @@ func NewSettingsFromValidationReq(validationReq *kubewarden_protocol.ValidationR
 	return settings, err
 }
 
-// Valid 对 Settings 本身做合法性校验.
+// Valid 对 Settings 本身做合法性校验，收集全部错误后一起返回，返回的错误为 FieldErrors.
 func (s *Settings) Valid() (bool, error) {
+	var errs FieldErrors
 	if s.EnvKey == "" {
-		return false, errors.New("env_key cannot be empty")
+		errs.add("env_key", "cannot be empty")
+	}
+	errs.merge(s.validProfile())
+	errs.merge(s.validAdditionalAnnotations())
+	errs.merge(s.validLabels())
+	errs.merge(s.validMode())
+	errs.merge(s.validOperations())
+	errs.merge(s.validLogLevel())
+	errs.merge(s.validLimits())
+	errs.merge(s.Namespaces.Valid())
+	errs.merge(s.Containers.Valid())
+	errs.merge(s.Exemptions.Valid())
+	validGlobPatterns(&errs, "audit_users", s.AuditUsers)
+	if s.Selector != nil {
+		errs.merge(s.Selector.Valid())
+	}
+	errs.merge(s.Markers.Valid())
+	if s.NamespaceOverrides != nil {
+		errs.merge(s.NamespaceOverrides.Valid())
+	}
+	if s.Provenance != nil {
+		errs.merge(s.Provenance.Valid(s))
 	}
-	if s.AnnotationBase == "" {
-		return false, errors.New("annotation_base cannot be empty")
+	errs.merge(s.PathRules.Valid())
+	if s.LogVolume != nil {
+		errs.merge(s.LogVolume.Valid())
+	}
+	if s.Sidecar != nil {
+		errs.merge(s.Sidecar.Valid())
+	}
+	if len(errs) > 0 {
+		return false, errs
+	}
+	return true, nil
+}
+
+// validPathAnnotations 校验 annotation_base、annotation_ext_format 及编码和编号相关的配置.
+func (s *Settings) validPathAnnotations() error {
+	var errs FieldErrors
+	if s.AnnotationBase == "" && s.firstPathUsesBase() && s.isNumbered() {
+		errs.add("annotation_base", "cannot be empty")
 	}
-	if s.AnnotationExtFormat == "" {
-		return false, errors.New("annotation_ext_format cannot be empty")
+	errs.merge(s.validEncoding())
+	if s.isNumbered() {
+		// 验证 AnnotationExtFormat 有且只有一个整数占位符
+		if s.AnnotationExtFormat == "" {
+			errs.add("annotation_ext_format", "cannot be empty")
+		} else if _, err := parseExtKeyFormat(s.AnnotationExtFormat); err != nil {
+			errs.add("annotation_ext_format", "%v", err)
+		}
+		errs.merge(s.validNumbering())
 	}
+	return errs.err()
+}
 
-	// 验证 AdditionalAnnotations 键值对
-	if s.AdditionalAnnotations != nil {
-		for key, value := range s.AdditionalAnnotations {
-			if key == "" {
-				return false, errors.New("additional_annotations keys cannot be empty")
-			}
-			// 允许布尔值、数字等非字符串类型
-			// 仅当值为字符串类型时检查是否为空
-			if strVal, ok := value.(string); ok {
-				if strVal == "" {
-					return false, errors.New("additional_annotations string values cannot be empty")
-				}
-			}
+// validAdditionalAnnotations 校验 additional_annotations 的键是否满足 Kubernetes 限定名规则，
+// 渲染器写入的键由各渲染器自行校验.
+func (s *Settings) validAdditionalAnnotations() error {
+	var errs FieldErrors
+	for _, key := range sortedKeys(s.AdditionalAnnotations) {
+		field := fieldKey("additional_annotations", key)
+		if key == "" {
+			errs.add(field, "key cannot be empty")
+		} else if err := validateQualifiedName(key); err != nil {
+			errs.add(field, "key is not a valid annotation key: %v", err)
+		}
+		// 允许布尔值、数字等非字符串类型，仅当值为字符串类型时检查是否为空
+		if value, ok := s.AdditionalAnnotations[key].(string); ok && value == "" {
+			errs.add(field, "string value cannot be empty")
 		}
 	}
+	return errs.err()
+}
 
-	// 验证 AnnotationExtFormat 是否包含格式化占位符 %d
-	if !strings.Contains(s.AnnotationExtFormat, "%d") {
-		return false, errors.New("annotation_ext_format must contain %d placeholder")
+// sampleExtIndexes 返回用于校验 annotation_ext_format 的样例序号，覆盖最短和较长的渲染结果.
+func (s *Settings) sampleExtIndexes() []int {
+	largest := maxSampleExtIndex
+	if s.MaxPaths > largest {
+		largest = s.MaxPaths
 	}
-	return true, nil
+	return []int{s.indexStart(), s.indexStart() + largest - 1}
+}
+
+// validMode 校验运行模式，validate 模式下不允许启用会修改 Pod 模板的功能.
+func (s *Settings) validMode() error {
+	var errs FieldErrors
+	switch s.Mode {
+	case "", ModeMutate:
+	case ModeValidate:
+		if s.LogVolume != nil && s.LogVolume.Enabled {
+			errs.add("log_volume.enabled", "cannot be enabled in validate mode")
+		}
+		if s.Sidecar != nil && s.Sidecar.Enabled {
+			errs.add("sidecar.enabled", "cannot be enabled in validate mode")
+		}
+		// provenance 注解的取值是策略配置的摘要，用户无法手写，校验时每个 Deployment 都会被拒绝
+		if s.Provenance != nil && s.Provenance.Enabled {
+			errs.add("provenance.enabled", "cannot be enabled in validate mode")
+		}
+	default:
+		errs.add("mode", "must be %s or %s, got %q", ModeMutate, ModeValidate, s.Mode)
+	}
+	return errs.err()
 }
 
 // validateSettings 由 Kubewarden 在策略加载时调用.
@@ -76,13 +214,20 @@ func validateSettings(payload []byte) ([]byte, error) {
 	}
 
 	valid, err := settings.Valid()
+	// 逐行列出全部错误，一次修正所有字段
+	var errs FieldErrors
+	if errors.As(err, &errs) {
+		logger.WarnWith("rejecting settings").Int("errors", len(errs)).Write()
+		return kubewarden.RejectSettings(kubewarden.Message("Provided settings are not valid:" + errs.list()))
+	}
+	// 其他错误或未通过校验时同样拒绝，不能默认接受
 	if err != nil {
+		logger.Warn("rejecting settings")
 		return kubewarden.RejectSettings(kubewarden.Message(fmt.Sprintf("Provided settings are not valid: %v", err)))
 	}
-	if valid {
-		return kubewarden.AcceptSettings()
+	if !valid {
+		logger.Warn("rejecting settings")
+		return kubewarden.RejectSettings(kubewarden.Message("Provided settings are not valid"))
 	}
-
-	logger.Warn("rejecting settings")
-	return kubewarden.RejectSettings(kubewarden.Message("Provided settings are not valid"))
+	return kubewarden.AcceptSettings()
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
@@ -37,6 +38,19 @@ func TestValidSettingsWithAdditionalAnnotations(t *testing.T) {
 	}
 }
 
+func TestValidSettingsWithProfile(t *testing.T) {
+	settings := Settings{
+		EnvKey:         "test_env",
+		Profile:        ProfileElasticHints,
+		ProfileOptions: json.RawMessage(`{"multiline": {"pattern": "^\\s", "match": "after"}}`),
+	}
+
+	valid, err := settings.Valid()
+	if !valid {
+		t.Errorf("Expected settings with a profile to be valid without annotation keys, got error: %v", err)
+	}
+}
+
 func TestInvalidSettingsAdditionalAnnotationsEmptyKey(t *testing.T) {
 	settings := Settings{
 		EnvKey:              "test_env",
@@ -51,8 +65,8 @@ func TestInvalidSettingsAdditionalAnnotationsEmptyKey(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to empty key in AdditionalAnnotations")
 	}
-	if err == nil || err.Error() != "additional_annotations keys cannot be empty" {
-		t.Errorf("Expected error 'additional_annotations keys cannot be empty', got: %v", err)
+	if err == nil || err.Error() != `additional_annotations[""]: key cannot be empty` {
+		t.Errorf(`Expected error 'additional_annotations[""]: key cannot be empty', got: %v`, err)
 	}
 }
 
@@ -70,8 +84,8 @@ func TestInvalidSettingsAdditionalAnnotationsEmptyValue(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to empty value in AdditionalAnnotations")
 	}
-	if err == nil || err.Error() != "additional_annotations string values cannot be empty" {
-		t.Errorf("Expected error 'additional_annotations string values cannot be empty', got: %v", err)
+	if err == nil || err.Error() != `additional_annotations["key1"]: string value cannot be empty` {
+		t.Errorf(`Expected error 'additional_annotations["key1"]: string value cannot be empty', got: %v`, err)
 	}
 }
 
@@ -104,8 +118,8 @@ func TestInvalidSettingsEmptyEnvKey(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to empty EnvKey")
 	}
-	if err == nil || err.Error() != "env_key cannot be empty" {
-		t.Errorf("Expected error 'env_key cannot be empty', got: %v", err)
+	if err == nil || err.Error() != "env_key: cannot be empty" {
+		t.Errorf("Expected error 'env_key: cannot be empty', got: %v", err)
 	}
 }
 
@@ -120,8 +134,8 @@ func TestInvalidSettingsEmptyAnnotationBase(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to empty AnnotationBase")
 	}
-	if err == nil || err.Error() != "annotation_base cannot be empty" {
-		t.Errorf("Expected error 'annotation_base cannot be empty', got: %v", err)
+	if err == nil || err.Error() != "annotation_base: cannot be empty" {
+		t.Errorf("Expected error 'annotation_base: cannot be empty', got: %v", err)
 	}
 }
 
@@ -136,8 +150,8 @@ func TestInvalidSettingsEmptyAnnotationExtFormat(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to empty AnnotationExtFormat")
 	}
-	if err == nil || err.Error() != "annotation_ext_format cannot be empty" {
-		t.Errorf("Expected error 'annotation_ext_format cannot be empty', got: %v", err)
+	if err == nil || err.Error() != "annotation_ext_format: cannot be empty" {
+		t.Errorf("Expected error 'annotation_ext_format: cannot be empty', got: %v", err)
 	}
 }
 
@@ -152,8 +166,125 @@ func TestInvalidSettingsAnnotationExtFormatMissingPlaceholder(t *testing.T) {
 	if valid {
 		t.Errorf("Expected settings to be invalid due to missing %%d placeholder in AnnotationExtFormat")
 	}
-	if err == nil || err.Error() != "annotation_ext_format must contain %d placeholder" {
-		t.Errorf("Expected error 'annotation_ext_format must contain %%d placeholder', got: %v", err)
+	if err == nil || err.Error() != "annotation_ext_format: must contain %d placeholder" {
+		t.Errorf("Expected error 'annotation_ext_format: must contain %%d placeholder', got: %v", err)
+	}
+}
+
+func TestInvalidSettingsAnnotationKeys(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{
+			name: "annotation base with extra slash",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "co.elastic.logs/path/extra",
+				AnnotationExtFormat: "test_ext_%d",
+			},
+			expectedError: `annotation_base: "co.elastic.logs/path/extra" is not a valid annotation key: ` +
+				"must be a name with an optional DNS subdomain prefix separated by a single '/'",
+		},
+		{
+			name: "ext format rendering an uppercase prefix",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "test_base",
+				AnnotationExtFormat: "Example.com/path_%d",
+			},
+			expectedError: `annotation_ext_format: "Example.com/path_%d" renders invalid annotation key ` +
+				`"Example.com/path_1": prefix part must be a lowercase RFC 1123 subdomain of at most 253 characters`,
+		},
+		{
+			name: "ext format rendering a name that is too long",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "test_base",
+				AnnotationExtFormat: strings.Repeat("a", 62) + "%d",
+			},
+			expectedError: `annotation_ext_format: "` + strings.Repeat("a", 62) + `%d" ` +
+				`renders invalid annotation key "` + strings.Repeat("a", 62) + `99": ` +
+				"name part must be no more than 63 characters",
+		},
+		{
+			name: "invalid additional annotation key",
+			settings: Settings{
+				EnvKey:                "test_env",
+				AnnotationBase:        "test_base",
+				AnnotationExtFormat:   "test_ext_%d",
+				AdditionalAnnotations: map[string]interface{}{"multiline pattern": "^\\s"},
+			},
+			expectedError: `additional_annotations["multiline pattern"]: key is not a valid annotation key: ` +
+				"name part must consist of alphanumeric characters, '-', '_' or '.', " +
+				"and must start and end with an alphanumeric character",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valid, err := test.settings.Valid()
+			if valid {
+				t.Errorf("Expected settings to be invalid")
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestInvalidSettingsMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expectedError string
+	}{
+		{
+			name: "unknown mode",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "test_base",
+				AnnotationExtFormat: "test_ext_%d",
+				Mode:                "audit",
+			},
+			expectedError: `mode: must be mutate or validate, got "audit"`,
+		},
+		{
+			name: "sidecar in validate mode",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "test_base",
+				AnnotationExtFormat: "test_ext_%d",
+				Mode:                ModeValidate,
+				Sidecar:             &SidecarSettings{Enabled: true, Image: "busybox"},
+			},
+			expectedError: "sidecar.enabled: cannot be enabled in validate mode",
+		},
+		{
+			name: "provenance in validate mode",
+			settings: Settings{
+				EnvKey:              "test_env",
+				AnnotationBase:      "test_base",
+				AnnotationExtFormat: "test_ext_%d",
+				Mode:                ModeValidate,
+				Provenance:          &ProvenanceSettings{Enabled: true},
+			},
+			expectedError: "provenance.enabled: cannot be enabled in validate mode",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valid, err := test.settings.Valid()
+			if valid {
+				t.Errorf("Expected settings to be invalid")
+			}
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error %q, got: %v", test.expectedError, err)
+			}
+		})
 	}
 }
 
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultSidecarName     = "log-shipper"
+	defaultSidecarPathsEnv = "LOG_PATHS"
+	// sidecarPathsPlaceholder 在参数中替换为逗号分隔的全部日志路径.
+	sidecarPathsPlaceholder = "{{paths}}"
+	// sidecarPathPlaceholder 所在的参数会按每个日志路径各展开一次.
+	sidecarPathPlaceholder = "{{path}}"
+	// sidecarMarkerEnv 标记由策略注入的 sidecar，只有带此标记的同名容器才会被替换.
+	sidecarMarkerEnv   = "ENV_TO_ANNOTATION_INJECTED"
+	sidecarMarkerValue = "true"
+)
+
+//nolint:gochecknoglobals // 预编译的正则表达式只读.
+var envVarNamePattern = regexp.MustCompile(`^[-._a-zA-Z][-._a-zA-Z0-9]*$`)
+
+// SidecarSettings 定义了日志采集 sidecar 的注入配置.
+type SidecarSettings struct {
+	// Enabled 是否向声明了 env_key 的 Pod 模板注入 sidecar
+	Enabled bool `json:"enabled"`
+	// Name sidecar 容器名称，同名容器由策略注入时原地替换，否则拒绝请求
+	Name string `json:"name,omitempty"`
+	// Image sidecar 镜像
+	Image string `json:"image"`
+	// Args 参数模板，支持 {{paths}} 和 {{path}} 占位符
+	Args []string `json:"args,omitempty"`
+	// PathsEnv 以逗号分隔的形式传递日志路径的环境变量名称
+	PathsEnv string `json:"paths_env,omitempty"`
+	// Resources sidecar 的资源限制
+	Resources SidecarResources `json:"resources,omitempty"`
+}
+
+// SidecarResources 定义了 sidecar 的 requests 和 limits.
+type SidecarResources struct {
+	Limits   map[string]string `json:"limits,omitempty"`
+	Requests map[string]string `json:"requests,omitempty"`
+}
+
+// Valid 校验 sidecar 配置.
+func (s *SidecarSettings) Valid() error {
+	if !s.Enabled {
+		return nil
+	}
+	var errs FieldErrors
+	if s.Image == "" {
+		errs.add("sidecar.image", "cannot be empty")
+	}
+	if !isDNSLabel(s.name()) {
+		errs.add("sidecar.name", "%q must be a DNS label", s.name())
+	}
+	if !envVarNamePattern.MatchString(s.pathsEnv()) {
+		errs.add("sidecar.paths_env", "%q is not a valid environment variable name", s.pathsEnv())
+	}
+	for _, name := range sortedKeys(s.Resources.Limits) {
+		if quantity := s.Resources.Limits[name]; !isQuantity(quantity) {
+			errs.add(fieldKey("sidecar.resources.limits", name), "%q is not a valid quantity", quantity)
+		}
+	}
+	for _, name := range sortedKeys(s.Resources.Requests) {
+		if quantity := s.Resources.Requests[name]; !isQuantity(quantity) {
+			errs.add(fieldKey("sidecar.resources.requests", name), "%q is not a valid quantity", quantity)
+		}
+	}
+	return errs.err()
+}
+
+func (s *SidecarSettings) name() string {
+	if s.Name == "" {
+		return defaultSidecarName
+	}
+	return s.Name
+}
+
+func (s *SidecarSettings) pathsEnv() string {
+	if s.PathsEnv == "" {
+		return defaultSidecarPathsEnv
+	}
+	return s.PathsEnv
+}
+
+// newContainer 根据日志路径渲染 sidecar 容器，mounts 为需要只读挂载的日志卷.
+func (s *SidecarSettings) newContainer(logPaths []string, mounts []*corev1.VolumeMount) *corev1.Container {
+	name := s.name()
+	envName := s.pathsEnv()
+	markerName := sidecarMarkerEnv
+	joined := strings.Join(logPaths, ",")
+
+	var args []string
+	for _, arg := range s.Args {
+		if strings.Contains(arg, sidecarPathPlaceholder) {
+			for _, logPath := range logPaths {
+				args = append(args, strings.ReplaceAll(arg, sidecarPathPlaceholder, logPath))
+			}
+			continue
+		}
+		args = append(args, strings.ReplaceAll(arg, sidecarPathsPlaceholder, joined))
+	}
+
+	container := &corev1.Container{
+		Name:         &name,
+		Image:        s.Image,
+		Args:         args,
+		Env:          []*corev1.EnvVar{{Name: &envName, Value: joined}, {Name: &markerName, Value: sidecarMarkerValue}},
+		VolumeMounts: mounts,
+	}
+	if len(s.Resources.Limits) > 0 || len(s.Resources.Requests) > 0 {
+		container.Resources = &corev1.ResourceRequirements{
+			Limits:   toQuantities(s.Resources.Limits),
+			Requests: toQuantities(s.Resources.Requests),
+		}
+	}
+	return container
+}
+
+// injectSidecar 注入读取被选中容器日志的 sidecar，同名的已注入 sidecar 原地替换以保证幂等，
+// 同名容器不是由策略注入时返回错误，避免覆盖用户自己的容器.
+func injectSidecar(podSpec *corev1.PodSpec, sources []containerLogPaths, settings *SidecarSettings) error {
+	var logPaths []string
+	var mounts []*corev1.VolumeMount
+	seen := map[string]*corev1.VolumeMount{}
+	for _, source := range sources {
+		logPaths = append(logPaths, source.logPaths...)
+		for _, mount := range sidecarMounts(source.container, logDirs(source.logPaths)) {
+			// 多个容器挂载同一个卷的同一位置时只保留一份，同一位置上的不同卷无法同时挂载到 sidecar
+			previous, ok := seen[*mount.MountPath]
+			if !ok {
+				seen[*mount.MountPath] = mount
+				mounts = append(mounts, mount)
+				continue
+			}
+			if *previous.Name != *mount.Name || previous.SubPath != mount.SubPath ||
+				previous.SubPathExpr != mount.SubPathExpr {
+				return fmt.Errorf("selected containers mount different volumes %q and %q at %q, "+
+					"the sidecar cannot mount both", *previous.Name, *mount.Name, *mount.MountPath)
+			}
+		}
+	}
+
+	sidecar := settings.newContainer(uniquePaths(logPaths), mounts)
+	for _, mount := range mounts {
+		// 按 Pod 划分的 hostPath 子目录需要与被读取的容器展开为同一路径
+		if mount.SubPathExpr == hostPathSubPathExpr {
+			ensurePodIdentityEnv(sidecar)
+		}
+	}
+	for i, container := range podSpec.Containers {
+		if container == nil || container.Name == nil || *container.Name != *sidecar.Name {
+			continue
+		}
+		if !isInjectedSidecar(container) {
+			return fmt.Errorf("container %q already exists and was not injected by the policy, "+
+				"rename it or set sidecar.name", *sidecar.Name)
+		}
+		podSpec.Containers[i] = sidecar
+		return nil
+	}
+	podSpec.Containers = append(podSpec.Containers, sidecar)
+	return nil
+}
+
+// isInjectedSidecar 判断容器是否带有策略注入 sidecar 时写入的标记.
+func isInjectedSidecar(container *corev1.Container) bool {
+	for _, env := range container.Env {
+		if env != nil && env.Name != nil && *env.Name == sidecarMarkerEnv && env.Value == sidecarMarkerValue {
+			return true
+		}
+	}
+	return false
+}
+
+// sidecarMounts 返回 source 容器中覆盖各日志目录的挂载的只读副本.
+func sidecarMounts(source *corev1.Container, dirs []string) []*corev1.VolumeMount {
+	var mounts []*corev1.VolumeMount
+	seen := map[*corev1.VolumeMount]bool{}
+	for _, dir := range dirs {
+		mount := coveringMount(source, dir)
+		if mount == nil || seen[mount] {
+			continue
+		}
+		seen[mount] = true
+		mounts = append(mounts, &corev1.VolumeMount{
+			Name:        mount.Name,
+			MountPath:   mount.MountPath,
+			SubPath:     mount.SubPath,
+			SubPathExpr: mount.SubPathExpr,
+			ReadOnly:    true,
+		})
+	}
+	return mounts
+}
+
+func uniquePaths(logPaths []string) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, logPath := range logPaths {
+		if !seen[logPath] {
+			seen[logPath] = true
+			paths = append(paths, logPath)
+		}
+	}
+	return paths
+}
+
+func toQuantities(values map[string]string) map[string]*resource.Quantity {
+	if len(values) == 0 {
+		return nil
+	}
+	quantities := make(map[string]*resource.Quantity, len(values))
+	for name, value := range values {
+		quantity := resource.Quantity(value)
+		quantities[name] = &quantity
+	}
+	return quantities
+}
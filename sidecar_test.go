@@ -0,0 +1,275 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestSidecarSettingsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings SidecarSettings
+		wantErr  bool
+	}{
+		{
+			name:     "disabled settings are not checked",
+			settings: SidecarSettings{},
+		},
+		{
+			name: "complete settings",
+			settings: SidecarSettings{
+				Enabled:   true,
+				Image:     "fluent/fluent-bit:3.0",
+				Args:      []string{"-i", "tail", "-p", "path={{paths}}"},
+				Resources: SidecarResources{Limits: map[string]string{"memory": "64Mi", "cpu": "100m"}},
+			},
+		},
+		{
+			name:     "missing image",
+			settings: SidecarSettings{Enabled: true},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid name",
+			settings: SidecarSettings{Enabled: true, Image: "busybox", Name: "Log_Shipper"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid paths env",
+			settings: SidecarSettings{Enabled: true, Image: "busybox", PathsEnv: "1PATHS"},
+			wantErr:  true,
+		},
+		{
+			name: "invalid resource quantity",
+			settings: SidecarSettings{
+				Enabled:   true,
+				Image:     "busybox",
+				Resources: SidecarResources{Requests: map[string]string{"memory": "a lot"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.Valid()
+			if test.wantErr && err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSidecarInjection(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Sidecar: &SidecarSettings{
+			Enabled:  true,
+			Image:    "busybox",
+			Args:     []string{"--files={{paths}}", "--follow={{path}}"},
+			PathsEnv: "TAIL_PATHS",
+		},
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("app"),
+							Env: []*corev1.EnvVar{
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app/info.log"},
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app/error.log"},
+								{Name: stringPtr("vestack_varlog"), Value: "/var/log/app/info.log"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	plan, err := planLogAnnotations(&deployment, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mutated, err := applyLogPlan(&deployment, plan, settings)
+	if err != nil || !mutated {
+		t.Fatalf("Expected deployment to be mutated, got error: %v", err)
+	}
+
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) != 2 {
+		t.Fatalf("Expected 2 containers, got %d", len(containers))
+	}
+	sidecar := containers[1]
+	if *sidecar.Name != "log-shipper" || sidecar.Image != "busybox" {
+		t.Errorf("Unexpected sidecar %s with image %s", *sidecar.Name, sidecar.Image)
+	}
+	expectedArgs := []string{
+		"--files=/var/log/app/info.log,/var/log/app/error.log",
+		"--follow=/var/log/app/info.log",
+		"--follow=/var/log/app/error.log",
+	}
+	if !reflect.DeepEqual(sidecar.Args, expectedArgs) {
+		t.Errorf("Expected args %v, got %v", expectedArgs, sidecar.Args)
+	}
+	if len(sidecar.Env) != 2 || *sidecar.Env[0].Name != "TAIL_PATHS" ||
+		sidecar.Env[0].Value != "/var/log/app/info.log,/var/log/app/error.log" || !isInjectedSidecar(sidecar) {
+		t.Errorf("Unexpected sidecar env %+v", sidecar.Env)
+	}
+
+	// 未配置 log_volume 时默认注入 emptyDir 卷并以只读方式共享给 sidecar
+	if len(deployment.Spec.Template.Spec.Volumes) != 1 || deployment.Spec.Template.Spec.Volumes[0].EmptyDir == nil {
+		t.Fatalf("Expected a shared emptyDir volume, got %+v", deployment.Spec.Template.Spec.Volumes)
+	}
+	if len(sidecar.VolumeMounts) != 1 || *sidecar.VolumeMounts[0].MountPath != "/var/log/app" ||
+		!sidecar.VolumeMounts[0].ReadOnly {
+		t.Errorf("Expected a read-only mount of /var/log/app, got %+v", sidecar.VolumeMounts)
+	}
+
+	// UPDATE 时再次执行不应重复注入 sidecar 或卷
+	if plan, err = planLogAnnotations(&deployment, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = applyLogPlan(&deployment, plan, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if count := len(deployment.Spec.Template.Spec.Containers); count != 2 {
+		t.Errorf("Expected sidecar to be injected once, got %d containers", count)
+	}
+	if count := len(deployment.Spec.Template.Spec.Volumes); count != 1 {
+		t.Errorf("Expected volume to be injected once, got %d volumes", count)
+	}
+}
+
+func TestSidecarDoesNotReplaceUserContainer(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Sidecar:             &SidecarSettings{Enabled: true, Image: "busybox"},
+	}
+	deployment := appsv1.Deployment{
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{
+							Name: stringPtr("app"),
+							Env:  []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: "/var/log/app/info.log"}},
+						},
+						{Name: stringPtr("log-shipper"), Image: "my-shipper"},
+					},
+				},
+			},
+		},
+	}
+
+	plan, err := planLogAnnotations(&deployment, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = applyLogPlan(&deployment, plan, settings)
+	expected := `container "log-shipper" already exists and was not injected by the policy, ` +
+		"rename it or set sidecar.name"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error %q, got: %v", expected, err)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[1].Image; image != "my-shipper" {
+		t.Errorf("Expected the user container to be kept, got image %q", image)
+	}
+}
+
+func TestSidecarMountsSharedDirectoryOnce(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Containers:          ContainerSelection{Names: []string{"*"}},
+		Sidecar:             &SidecarSettings{Enabled: true, Image: "busybox"},
+	}
+	newContainer := func(name, logPath string, mounts ...*corev1.VolumeMount) *corev1.Container {
+		return &corev1.Container{
+			Name:         stringPtr(name),
+			Env:          []*corev1.EnvVar{{Name: stringPtr("vestack_varlog"), Value: logPath}},
+			VolumeMounts: mounts,
+		}
+	}
+
+	deployment := appsv1.Deployment{Spec: &appsv1.DeploymentSpec{Template: &corev1.PodTemplateSpec{
+		Spec: &corev1.PodSpec{Containers: []*corev1.Container{
+			newContainer("a", "/var/log/a.log"),
+			newContainer("b", "/var/log/b.log"),
+		}},
+	}}}
+	plan, err := planLogAnnotations(&deployment, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = applyLogPlan(&deployment, plan, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	podSpec := deployment.Spec.Template.Spec
+	if len(podSpec.Volumes) != 1 {
+		t.Errorf("Expected 1 shared volume, got %d", len(podSpec.Volumes))
+	}
+	if sidecar := podSpec.Containers[2]; len(sidecar.VolumeMounts) != 1 {
+		t.Errorf("Expected 1 sidecar mount, got %+v", sidecar.VolumeMounts)
+	}
+
+	// 用户自己的不同卷挂载在同一目录时 sidecar 无法同时挂载，拒绝而不是生成非法的 Pod
+	deployment = appsv1.Deployment{Spec: &appsv1.DeploymentSpec{Template: &corev1.PodTemplateSpec{
+		Spec: &corev1.PodSpec{Containers: []*corev1.Container{
+			newContainer("a", "/var/log/a.log",
+				&corev1.VolumeMount{Name: stringPtr("logs-a"), MountPath: stringPtr("/var/log")}),
+			newContainer("b", "/var/log/b.log",
+				&corev1.VolumeMount{Name: stringPtr("logs-b"), MountPath: stringPtr("/var/log")}),
+		}},
+	}}}
+	if plan, err = planLogAnnotations(&deployment, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = applyLogPlan(&deployment, plan, settings)
+	expected := `selected containers mount different volumes "logs-a" and "logs-b" at "/var/log", ` +
+		"the sidecar cannot mount both"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got: %v", expected, err)
+	}
+}
+
+func TestSidecarReadsPerPodHostPath(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		LogVolume:           &LogVolumeSettings{Enabled: true, Type: "hostPath", HostPathPrefix: "/var/log/pods-files"},
+		Sidecar:             &SidecarSettings{Enabled: true, Image: "busybox"},
+	}
+	deployment := newTestDeployment("app", envContainer("app", "vestack_varlog", "/var/log/app/info.log"))
+	plan, err := planLogAnnotations(&deployment, settings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = applyLogPlan(&deployment, plan, settings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sidecar := deployment.Spec.Template.Spec.Containers[1]
+	if len(sidecar.VolumeMounts) != 1 || sidecar.VolumeMounts[0].SubPathExpr != hostPathSubPathExpr {
+		t.Fatalf("Expected the sidecar to mount the per-pod subdirectory, got %+v", sidecar.VolumeMounts)
+	}
+	for _, name := range []string{podNamespaceEnv, podNameEnv} {
+		if !hasEnv(sidecar, name) {
+			t.Errorf("Expected the sidecar to define %s", name)
+		}
+	}
+}
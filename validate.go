@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
@@ -29,100 +31,333 @@ func validate(payload []byte) ([]byte, error) {
 	return processDeployment(validationRequest, settings)
 }
 
-// processDeployment 处理 Deployment 类型的资源.
+// processDeployment 处理 Deployment 类型的资源，每个准入决定都会写出一条结构化日志.
 func processDeployment(req kubewarden_protocol.ValidationRequest, settings Settings) ([]byte, error) {
+	d := newDecision(settings.newLogger(), &req.Request)
 	if req.Request.Kind.Kind != "Deployment" {
-		return kubewarden.AcceptRequest()
+		return d.skip("kind is not Deployment")
+	}
+	// scale 和 status 子资源不包含 Pod 模板
+	if isIgnoredSubResource(req.Request.SubResource) {
+		return d.skip(fmt.Sprintf("%s subresource is ignored", req.Request.SubResource))
+	}
+	if !settings.handlesOperation(req.Request.Operation) {
+		return d.skip(fmt.Sprintf("operation %q is not listed in operations", req.Request.Operation))
+	}
+	// 在反序列化对象之前过滤命名空间，被排除的请求开销最小
+	if !settings.Namespaces.applies(req.Request.Namespace) {
+		return d.skip("namespace is not selected by namespaces")
+	}
+	// 豁免的请求发起者（如 GitOps 控制器）直接放行，避免与其反复争夺注解
+	if reason := settings.Exemptions.exemptionReason(req.Request.UserInfo); reason != "" {
+		return d.accept("requester is exempt, " + reason)
+	}
+	settings, err := applyNamespaceOverrides(&host, settings, req.Request.Namespace)
+	if err != nil {
+		return d.reject(err.Error())
 	}
 
 	var deployment appsv1.Deployment
-	if err := json.Unmarshal(req.Request.Object, &deployment); err != nil {
-		return kubewarden.RejectRequest(kubewarden.Message("cannot unmarshal deployment"), kubewarden.Code(RejectCode))
+	if err = json.Unmarshal(req.Request.Object, &deployment); err != nil {
+		return d.reject("cannot unmarshal deployment")
+	}
+	d.observe(&deployment)
+	// 退出或未加入的工作负载在任何校验和修改之前直接放行
+	if reason := settings.Markers.skipReason(&deployment); reason != "" {
+		return d.skip(reason)
+	}
+	if settings.Selector != nil && !settings.Selector.matchesDeployment(&deployment) {
+		return d.skip("deployment labels do not match selector")
+	}
+
+	// 日志路径会被节点级采集器直接读取，先拒绝不安全的路径
+	for _, container := range settings.Containers.selectContainers(&deployment) {
+		if err = checkContainerLogPaths(container, settings.EnvKey, &settings.PathRules); err != nil {
+			return d.reject(err.Error())
+		}
 	}
 
-	mutated := mutateDeploymentContainers(&deployment, settings)
+	if settings.Mode == ModeValidate {
+		return validateDeploymentAnnotations(d, &deployment, settings)
+	}
+	if isAuditRequest(req.Request, &settings) {
+		return auditDeployment(d, &deployment, settings)
+	}
+	unchanged, err := envUnchanged(req.Request, &deployment, settings)
+	if err != nil {
+		return d.reject(err.Error())
+	}
+	return mutateDeployment(d, &deployment, settings, unchanged)
+}
 
+// mutateDeployment 把计算出的注解和标签写入 Pod 模板，没有需要写入的内容时原样放行.
+// envUnchanged 为 true 表示 UPDATE 未改动 env_key 相关字段，此时 Pod 模板仍带有期望的注解和标签就不重写，
+// 避免覆盖其他控制器或用户对 Pod 模板的修改；server-side apply 或 replace 丢弃了注解时仍会补回.
+func mutateDeployment(
+	d *decision,
+	deployment *appsv1.Deployment,
+	settings Settings,
+	envUnchanged bool,
+) ([]byte, error) {
+	plan, err := planLogAnnotations(deployment, settings)
+	if err != nil {
+		return d.reject(err.Error())
+	}
+	before := snapshotTemplate(deployment)
+	if envUnchanged && !templateDrifted(plan, deployment) {
+		d.recordPlan(plan, before, before)
+		return d.skip("update does not change env_key in the selected containers and the pod template is up to date")
+	}
+	mutated, err := applyLogPlan(deployment, plan, settings)
+	if err != nil {
+		return d.reject(err.Error())
+	}
+	d.recordPlan(plan, before, snapshotTemplate(deployment))
 	if !mutated {
-		return kubewarden.AcceptRequest()
+		return d.accept("no log paths or labels to write")
 	}
+	return d.mutate(deployment, "pod template updated")
+}
 
-	return kubewarden.MutateRequest(deployment)
+// validateDeploymentAnnotations 在 validate 模式下校验 Pod 模板注解，缺失或不一致时拒绝请求.
+func validateDeploymentAnnotations(d *decision, deployment *appsv1.Deployment, settings Settings) ([]byte, error) {
+	plan, err := planLogAnnotations(deployment, settings)
+	if err != nil {
+		return d.reject(err.Error())
+	}
+	before := snapshotTemplate(deployment)
+	d.recordPlan(plan, before, before.withPlan(plan))
+
+	var problems []string
+	if drift, drifted := describeDrift(plan.annotations, templateAnnotations(deployment)); drifted {
+		problems = append(problems, "pod template annotations do not match the expected values: "+drift)
+	}
+	if drift, drifted := describeDrift(plan.labels, templateLabels(deployment)); drifted {
+		problems = append(problems, "pod template labels do not match the expected values: "+drift)
+	}
+	if len(problems) > 0 {
+		return d.reject(strings.Join(problems, "; "))
+	}
+	return d.accept("pod template matches the expected values")
+}
+
+// describeDrift 逐个列出期望的注解或标签键值，并标注缺失或取值不同的项.
+func describeDrift(expected, actual map[string]string) (string, bool) {
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	drifted := false
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entry := fmt.Sprintf("%s=%q", key, expected[key])
+		if value, ok := actual[key]; !ok {
+			entry += " (missing)"
+			drifted = true
+		} else if value != expected[key] {
+			entry += fmt.Sprintf(" (found %q)", value)
+			drifted = true
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ", "), drifted
+}
+
+// templateDrifted 判断 Pod 模板是否缺少 plan 中的注解或标签，或取值不同.
+func templateDrifted(plan logPlan, deployment *appsv1.Deployment) bool {
+	_, annotationsDrifted := describeDrift(plan.annotations, templateAnnotations(deployment))
+	_, labelsDrifted := describeDrift(plan.labels, templateLabels(deployment))
+	return annotationsDrifted || labelsDrifted
 }
 
-func mutateDeploymentContainers(deployment *appsv1.Deployment, settings Settings) bool {
-	mutated := false
+// templateAnnotations 返回 Pod 模板当前的注解，不存在时返回 nil.
+func templateAnnotations(deployment *appsv1.Deployment) map[string]string {
+	if deployment.Spec == nil || deployment.Spec.Template == nil || deployment.Spec.Template.Metadata == nil {
+		return nil
+	}
+	return deployment.Spec.Template.Metadata.Annotations
+}
+
+// applyLogPlan 把 plan 写入 Pod 模板并按配置注入日志卷和 sidecar，plan 为空时不修改并返回 false.
+func applyLogPlan(deployment *appsv1.Deployment, plan logPlan, settings Settings) (bool, error) {
+	if len(plan.annotations)+len(plan.labels) == 0 {
+		return false, nil
+	}
+
 	if deployment.Spec.Template.Metadata == nil {
 		deployment.Spec.Template.Metadata = &metav1.ObjectMeta{}
 	}
 	if deployment.Spec.Template.Metadata.Annotations == nil {
 		deployment.Spec.Template.Metadata.Annotations = map[string]string{}
 	}
+	for key, value := range plan.annotations {
+		deployment.Spec.Template.Metadata.Annotations[key] = value
+	}
+	// 只修改 Pod 模板的标签，spec.selector 保持不变
+	if len(plan.labels) > 0 && deployment.Spec.Template.Metadata.Labels == nil {
+		deployment.Spec.Template.Metadata.Labels = map[string]string{}
+	}
+	for key, value := range plan.labels {
+		deployment.Spec.Template.Metadata.Labels[key] = value
+	}
 
-	if len(deployment.Spec.Template.Spec.Containers) > 0 {
-		if processContainerEnv(
-			deployment.Spec.Template.Spec.Containers[0],
-			deployment.Spec.Template.Metadata.Annotations,
-			settings,
-		) {
-			mutated = true
+	if len(plan.logPaths) > 0 {
+		if err := injectLogShipping(deployment.Spec.Template.Spec, plan.containers, settings); err != nil {
+			return false, err
 		}
 	}
+	return true, nil
+}
 
-	// 添加自定义注解的条件判断
-	envExists := false
-	if len(deployment.Spec.Template.Spec.Containers) > 0 {
-		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
-			if env != nil && env.Name != nil && *env.Name == settings.EnvKey {
-				envExists = true
-				break
-			}
+// logPlan 是根据 Pod 模板计算出的期望注解、标签及其使用的日志路径.
+type logPlan struct {
+	annotations map[string]string
+	labels      map[string]string
+	// containers 被选中的容器及各自的日志路径
+	containers []containerLogPaths
+	// logPaths 按容器顺序排列的全部日志路径
+	logPaths []string
+}
+
+// containerLogPaths 是一个被选中的容器及其日志路径.
+type containerLogPaths struct {
+	container *corev1.Container
+	logPaths  []string
+}
+
+// planLogAnnotations 计算 Pod 模板应当携带的注解，不修改 Deployment.
+// 超出 max_paths 或 max_annotation_bytes 时按 on_limit_exceeded 截断日志路径或返回错误，
+// 截断时从最后一个有日志路径的容器开始丢弃.
+func planLogAnnotations(deployment *appsv1.Deployment, settings Settings) (logPlan, error) {
+	var selected []containerLogPaths
+	for _, container := range settings.Containers.selectContainers(deployment) {
+		logPaths, err := settings.limitLogPaths(container, collectLogPaths(container, settings.EnvKey))
+		if err != nil {
+			return logPlan{}, err
+		}
+		if err = settings.checkEncodable(logPaths); err != nil {
+			return logPlan{}, err
+		}
+		selected = append(selected, containerLogPaths{container: container, logPaths: logPaths})
+	}
+
+	for {
+		plan := logPlan{containers: selected}
+		for _, entry := range selected {
+			plan.logPaths = append(plan.logPaths, entry.logPaths...)
+		}
+		annotations, err := containerAnnotations(deployment, selected, settings)
+		if err != nil {
+			return logPlan{}, err
+		}
+		// provenance 注解计入 max_annotation_bytes
+		if err = settings.addProvenance(annotations); err != nil {
+			return logPlan{}, err
+		}
+		plan.annotations = annotations
+		err = settings.checkAnnotationBytes(templateAnnotations(deployment), plan.annotations)
+		if err == nil || !settings.truncateOnLimit() || !dropLastLogPath(selected) {
+			plan.labels = settings.planLabels(templateLabels(deployment), selected)
+			return plan, err
 		}
 	}
+}
 
-	if envExists && settings.AdditionalAnnotations != nil {
+// dropLastLogPath 丢弃最后一个有日志路径的容器的最后一个路径，没有可丢弃的路径时返回 false.
+func dropLastLogPath(selected []containerLogPaths) bool {
+	for i := len(selected) - 1; i >= 0; i-- {
+		if n := len(selected[i].logPaths); n > 0 {
+			selected[i].logPaths = selected[i].logPaths[:n-1]
+			return true
+		}
+	}
+	return false
+}
+
+// containerAnnotations 由 profile 选择的渲染器根据容器的日志路径生成期望的注解.
+func containerAnnotations(
+	deployment *appsv1.Deployment,
+	selected []containerLogPaths,
+	settings Settings,
+) (map[string]string, error) {
+	renderer, err := settings.renderer()
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := renderer.Render(newDiscoveryResult(deployment, selected))
+	if err != nil {
+		return nil, err
+	}
+
+	// 添加自定义注解的条件判断
+	if anyHasEnv(selected, settings.EnvKey) {
+		if err = settings.checkNamespaceAnnotations(annotations); err != nil {
+			return nil, err
+		}
 		for key, value := range settings.AdditionalAnnotations {
 			if value != nil {
 				// 调用类型转换函数
-				strValue := convertToString(value)
-				deployment.Spec.Template.Metadata.Annotations[key] = strValue
-				mutated = true
+				annotations[key] = convertToString(value)
 			}
 		}
 	}
+	return annotations, nil
+}
+
+// injectLogShipping 按配置为被选中的容器注入共享日志卷和日志采集 sidecar.
+func injectLogShipping(podSpec *corev1.PodSpec, selected []containerLogPaths, settings Settings) error {
+	sidecarEnabled := settings.Sidecar != nil && settings.Sidecar.Enabled
+	logVolume := settings.LogVolume
+	if sidecarEnabled && (logVolume == nil || !logVolume.Enabled) {
+		// sidecar 只能读取共享卷上的文件，未显式配置日志卷时使用默认的 emptyDir 卷
+		logVolume = &LogVolumeSettings{Enabled: true}
+	}
 
-	return mutated
+	if logVolume != nil && logVolume.Enabled {
+		for _, entry := range selected {
+			ensureLogVolumes(podSpec, entry.container, entry.logPaths, logVolume)
+		}
+	}
+	if sidecarEnabled {
+		return injectSidecar(podSpec, selected, settings.Sidecar)
+	}
+	return nil
 }
 
-func processContainerEnv(container *corev1.Container, annotations map[string]string, settings Settings) bool {
-	if container == nil {
-		return false
+// hasEnv 判断容器是否声明了名为 envKey 的环境变量.
+func hasEnv(container *corev1.Container, envKey string) bool {
+	for _, env := range container.Env {
+		if env != nil && env.Name != nil && *env.Name == envKey {
+			return true
+		}
 	}
+	return false
+}
+
+// anyHasEnv 判断是否有被选中的容器声明了名为 envKey 的环境变量.
+func anyHasEnv(selected []containerLogPaths, envKey string) bool {
+	for _, entry := range selected {
+		if hasEnv(entry.container, envKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectLogPaths 按声明顺序收集容器中名为 envKey 的环境变量值.
+func collectLogPaths(container *corev1.Container, envKey string) []string {
 	var logPaths []string
 	for _, env := range container.Env {
 		if env == nil || env.Name == nil {
 			continue
 		}
-		if *env.Name == settings.EnvKey {
+		if *env.Name == envKey {
 			logPaths = append(logPaths, env.Value)
 		}
 	}
-
-	if len(logPaths) > 0 {
-		if container.Name == nil {
-			return false
-		}
-		for i, path := range logPaths {
-			var annotationKey string
-			if i == 0 {
-				annotationKey = settings.AnnotationBase
-			} else {
-				annotationKey = fmt.Sprintf(settings.AnnotationExtFormat, i)
-			}
-			annotations[annotationKey] = path
-		}
-		return true
-	}
-	return false
+	return logPaths
 }
 
 func convertToString(value interface{}) string {
@@ -132,6 +132,53 @@ func TestDeploymentMutation(t *testing.T) {
 			},
 			shouldMutate: true,
 		},
+		{
+			name: "deployment with containers selected by image",
+			settings: Settings{
+				EnvKey:              "vestack_varlog",
+				AnnotationBase:      "co_elastic_logs_path",
+				AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+				Containers:          ContainerSelection{Images: []string{"registry.example.com/apps/*"}},
+			},
+			deployment: appsv1.Deployment{
+				Spec: &appsv1.DeploymentSpec{
+					Template: &corev1.PodTemplateSpec{
+						Metadata: &metav1.ObjectMeta{},
+						Spec: &corev1.PodSpec{
+							Containers: []*corev1.Container{
+								{
+									Name:  stringPtr("proxy"),
+									Image: "envoyproxy/envoy:v1.30",
+									Env: []*corev1.EnvVar{
+										{Name: stringPtr("vestack_varlog"), Value: "/var/log/proxy.log"},
+									},
+								},
+								{
+									Name:  stringPtr("app"),
+									Image: "registry.example.com/apps/api:1.2",
+									Env: []*corev1.EnvVar{
+										{Name: stringPtr("vestack_varlog"), Value: "/var/log/api.log"},
+									},
+								},
+								{
+									Name:  stringPtr("worker"),
+									Image: "registry.example.com/apps/worker@sha256:abc",
+									Env: []*corev1.EnvVar{
+										{Name: stringPtr("vestack_varlog"), Value: "/var/log/worker.log"},
+									},
+								},
+							},
+						},
+					},
+				},
+				Metadata: &metav1.ObjectMeta{},
+			},
+			expectedAnnotations: map[string]string{
+				"co_elastic_logs_path":       "/var/log/api.log",
+				"co_elastic_logs_path_ext_1": "/var/log/worker.log",
+			},
+			shouldMutate: true,
+		},
 		{
 			name: "container with nil name",
 			settings: Settings{
@@ -404,11 +451,110 @@ func assertNoMutation(t *testing.T, response *kubewarden_protocol.ValidationResp
 	}
 }
 
+func TestValidateMode(t *testing.T) {
+	settings := Settings{
+		EnvKey:              "vestack_varlog",
+		AnnotationBase:      "co_elastic_logs_path",
+		AnnotationExtFormat: "co_elastic_logs_path_ext_%d",
+		Mode:                ModeValidate,
+		AdditionalAnnotations: map[string]interface{}{
+			"co_elastic_logs_multiline_match": "after",
+		},
+	}
+	newDeployment := func(annotations map[string]string) appsv1.Deployment {
+		deployment := newTestDeployment("",
+			envContainer("my-container", "vestack_varlog", "/var/log/app.log", "/var/log/error.log"))
+		deployment.Spec.Template.Metadata.Annotations = annotations
+		return deployment
+	}
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		expectedMessage string
+	}{
+		{
+			name: "annotations in place",
+			annotations: map[string]string{
+				"co_elastic_logs_path":            "/var/log/app.log",
+				"co_elastic_logs_path_ext_1":      "/var/log/error.log",
+				"co_elastic_logs_multiline_match": "after",
+				"unrelated":                       "value",
+			},
+		},
+		{
+			name: "missing and drifted annotations",
+			annotations: map[string]string{
+				"co_elastic_logs_path":       "/var/log/app.log",
+				"co_elastic_logs_path_ext_1": "/var/log/old.log",
+			},
+			expectedMessage: "pod template annotations do not match the expected values: " +
+				`co_elastic_logs_multiline_match="after" (missing), ` +
+				`co_elastic_logs_path="/var/log/app.log", ` +
+				`co_elastic_logs_path_ext_1="/var/log/error.log" (found "/var/log/old.log")`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := kubewarden_protocol.ValidationRequest{
+				Request: kubewarden_protocol.KubernetesAdmissionRequest{
+					Kind:   kubewarden_protocol.GroupVersionKind{Kind: "Deployment"},
+					Object: json.RawMessage(mustMarshalJSON(newDeployment(test.annotations))),
+				},
+				Settings: json.RawMessage(mustMarshalJSON(settings)),
+			}
+
+			response, err := validateTest(t, req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.MutatedObject != nil {
+				t.Errorf("Expected no mutation in validate mode")
+			}
+			if test.expectedMessage == "" {
+				if !response.Accepted {
+					t.Errorf("Expected request to be accepted, got rejected: %s", *response.Message)
+				}
+				return
+			}
+			if response.Accepted {
+				t.Fatalf("Expected request to be rejected")
+			}
+			if *response.Message != test.expectedMessage {
+				t.Errorf("Expected message %q, got %q", test.expectedMessage, *response.Message)
+			}
+		})
+	}
+}
+
 // stringPtr 返回一个指向给定字符串的指针.
 func stringPtr(s string) *string {
 	return &s
 }
 
+// newTestDeployment 构造名为 name、Pod 模板中只有 containers 的 Deployment，测试按需补充注解和标签.
+func newTestDeployment(name string, containers ...*corev1.Container) appsv1.Deployment {
+	return appsv1.Deployment{
+		Metadata: &metav1.ObjectMeta{Name: name},
+		Spec: &appsv1.DeploymentSpec{
+			Template: &corev1.PodTemplateSpec{
+				Metadata: &metav1.ObjectMeta{},
+				Spec:     &corev1.PodSpec{Containers: containers},
+			},
+		},
+	}
+}
+
+// envContainer 构造一个按顺序声明了多个 envKey 环境变量的容器.
+func envContainer(name, envKey string, values ...string) *corev1.Container {
+	container := &corev1.Container{Name: stringPtr(name)}
+	for _, value := range values {
+		container.Env = append(container.Env, &corev1.EnvVar{Name: stringPtr(envKey), Value: value})
+	}
+	return container
+}
+
 // mustMarshalJSON 是一个辅助函数，用于将 Go 对象序列化为 JSON 字节数组.
 func mustMarshalJSON(obj interface{}) []byte {
 	data, err := json.Marshal(obj)
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+)
+
+const (
+	logVolumeTypeEmptyDir       = "emptyDir"
+	logVolumeTypeHostPath       = "hostPath"
+	defaultLogVolumeNamePrefix  = "env-log"
+	hostPathTypeDirectoryCreate = "DirectoryOrCreate"
+	// logVolumeNameSuffixLength 为卷名称中的 "-<序号>" 后缀预留的长度.
+	logVolumeNameSuffixLength = 4
+	globMetaChars             = "*?["
+	// podNamespaceEnv 和 podNameEnv 通过 downward API 暴露 Pod 的命名空间和名称，供 hostPathSubPathExpr 引用.
+	podNamespaceEnv = "ENV_TO_ANNOTATION_POD_NAMESPACE"
+	podNameEnv      = "ENV_TO_ANNOTATION_POD_NAME"
+	// hostPathSubPathExpr 让每个 Pod 挂载 hostPath 卷下自己的子目录，不同 Pod 和租户的日志文件互不覆盖.
+	hostPathSubPathExpr = "$(" + podNamespaceEnv + ")/$(" + podNameEnv + ")"
+)
+
+//nolint:gochecknoglobals // 预编译的正则表达式只读.
+var quantityPattern = regexp.MustCompile(`^[+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([KMGTPE]i|[mkMGTPE]|[eE][+-]?[0-9]+)?$`)
+
+// LogVolumeSettings 定义了日志卷自动注入的配置.
+type LogVolumeSettings struct {
+	// Enabled 是否为未落在任何已挂载卷上的日志目录注入共享卷
+	Enabled bool `json:"enabled"`
+	// Type 注入卷的类型，可选 emptyDir(默认) 或 hostPath
+	Type string `json:"type,omitempty"`
+	// HostPathPrefix hostPath 类型时节点上的目录前缀，日志目录及 <命名空间>/<Pod 名称> 会拼接在其后
+	HostPathPrefix string `json:"host_path_prefix,omitempty"`
+	// NamePrefix 注入卷的名称前缀，实际名称为 <name_prefix>-<序号>
+	NamePrefix string `json:"name_prefix,omitempty"`
+	// SizeLimit emptyDir 卷的容量上限，例如 500Mi
+	SizeLimit string `json:"size_limit,omitempty"`
+}
+
+// Valid 校验日志卷配置.
+func (v *LogVolumeSettings) Valid() error {
+	if !v.Enabled {
+		return nil
+	}
+
+	var errs FieldErrors
+	switch v.volumeType() {
+	case logVolumeTypeEmptyDir:
+		if v.SizeLimit != "" && !isQuantity(v.SizeLimit) {
+			errs.add("log_volume.size_limit", "%q is not a valid quantity", v.SizeLimit)
+		}
+	case logVolumeTypeHostPath:
+		if v.HostPathPrefix == "" {
+			errs.add("log_volume.host_path_prefix", "cannot be empty when type is hostPath")
+		} else if !path.IsAbs(v.HostPathPrefix) {
+			errs.add("log_volume.host_path_prefix", "must be an absolute path")
+		}
+		if v.SizeLimit != "" {
+			errs.add("log_volume.size_limit", "is only supported by emptyDir volumes")
+		}
+	default:
+		errs.add("log_volume.type", "must be %s or %s, got %q", logVolumeTypeEmptyDir, logVolumeTypeHostPath, v.Type)
+	}
+
+	prefix := v.namePrefix()
+	if !isDNSLabel(prefix) || len(prefix) > dnsLabelMaxLength-logVolumeNameSuffixLength {
+		errs.add("log_volume.name_prefix", "%q must be a DNS label of at most %d characters",
+			prefix, dnsLabelMaxLength-logVolumeNameSuffixLength)
+	}
+	return errs.err()
+}
+
+func (v *LogVolumeSettings) volumeType() string {
+	if v.Type == "" {
+		return logVolumeTypeEmptyDir
+	}
+	return v.Type
+}
+
+func (v *LogVolumeSettings) namePrefix() string {
+	if v.NamePrefix == "" {
+		return defaultLogVolumeNamePrefix
+	}
+	return v.NamePrefix
+}
+
+// newVolume 按配置构造挂载 dir 的卷.
+func (v *LogVolumeSettings) newVolume(name, dir string) *corev1.Volume {
+	volume := &corev1.Volume{Name: &name}
+	if v.volumeType() == logVolumeTypeHostPath {
+		hostPath := path.Join(v.HostPathPrefix, dir)
+		volume.HostPath = &corev1.HostPathVolumeSource{
+			Path: &hostPath,
+			Type: hostPathTypeDirectoryCreate,
+		}
+		return volume
+	}
+
+	volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	if v.SizeLimit != "" {
+		sizeLimit := resource.Quantity(v.SizeLimit)
+		volume.EmptyDir.SizeLimit = &sizeLimit
+	}
+	return volume
+}
+
+// ensureLogVolumes 为未落在任何已挂载卷上的日志目录注入卷及对应的 volumeMount，
+// 其他容器已在同一目录挂载了注入的卷时共用该卷，使 sidecar 对每个目录只需要一个挂载.
+// hostPath 卷按 Pod 挂载子目录，容器中会补上子目录表达式引用的环境变量.
+func ensureLogVolumes(
+	podSpec *corev1.PodSpec,
+	container *corev1.Container,
+	logPaths []string,
+	settings *LogVolumeSettings,
+) bool {
+	mutated := false
+	for _, dir := range logDirs(logPaths) {
+		if coveringMount(container, dir) != nil {
+			continue
+		}
+		name := sharedLogVolume(podSpec, dir, settings.namePrefix())
+		if name == "" {
+			name = nextLogVolumeName(podSpec, settings.namePrefix())
+			podSpec.Volumes = append(podSpec.Volumes, settings.newVolume(name, dir))
+		}
+		mountPath := dir
+		mount := &corev1.VolumeMount{Name: &name, MountPath: &mountPath}
+		if settings.volumeType() == logVolumeTypeHostPath {
+			mount.SubPathExpr = hostPathSubPathExpr
+			ensurePodIdentityEnv(container)
+		}
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+		mutated = true
+	}
+	return mutated
+}
+
+// ensurePodIdentityEnv 为容器补上 hostPathSubPathExpr 引用的环境变量，已存在的同名变量保持不变.
+func ensurePodIdentityEnv(container *corev1.Container) {
+	for _, env := range []struct{ name, fieldPath string }{
+		{podNamespaceEnv, "metadata.namespace"},
+		{podNameEnv, "metadata.name"},
+	} {
+		if hasEnv(container, env.name) {
+			continue
+		}
+		name, fieldPath := env.name, env.fieldPath
+		container.Env = append(container.Env, &corev1.EnvVar{
+			Name:      &name,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: &fieldPath}},
+		})
+	}
+}
+
+// logDirs 返回日志路径所在的去重目录，父目录排在子目录之前.
+func logDirs(logPaths []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, logPath := range logPaths {
+		if !path.IsAbs(logPath) {
+			continue
+		}
+		dir := logDir(logPath)
+		if dir == "/" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// logDir 返回日志路径中第一个通配符片段之前的目录.
+func logDir(logPath string) string {
+	segments := strings.Split(path.Clean(logPath), "/")
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, globMetaChars) {
+			return path.Clean("/" + strings.Join(segments[:i], "/"))
+		}
+	}
+	return path.Dir(path.Clean(logPath))
+}
+
+// coveringMount 返回挂载路径包含 dir 的最长挂载，没有时返回 nil.
+func coveringMount(container *corev1.Container, dir string) *corev1.VolumeMount {
+	var best *corev1.VolumeMount
+	bestLen := -1
+	for _, mount := range container.VolumeMounts {
+		if mount == nil || mount.MountPath == nil || mount.Name == nil {
+			continue
+		}
+		mountPath := path.Clean(*mount.MountPath)
+		if mountPath != "/" && dir != mountPath && !strings.HasPrefix(dir, mountPath+"/") {
+			continue
+		}
+		if len(mountPath) > bestLen {
+			best = mount
+			bestLen = len(mountPath)
+		}
+	}
+	return best
+}
+
+// sharedLogVolume 返回其他容器挂载在 dir 上的注入卷名称，没有时返回空字符串.
+func sharedLogVolume(podSpec *corev1.PodSpec, dir, prefix string) string {
+	for _, container := range podSpec.Containers {
+		if container == nil {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if mount == nil || mount.Name == nil || mount.MountPath == nil || mount.SubPath != "" {
+				continue
+			}
+			if path.Clean(*mount.MountPath) == dir && strings.HasPrefix(*mount.Name, prefix+"-") {
+				return *mount.Name
+			}
+		}
+	}
+	return ""
+}
+
+// nextLogVolumeName 返回 Pod 中尚未被占用的第一个 <prefix>-<序号> 卷名称.
+func nextLogVolumeName(podSpec *corev1.PodSpec, prefix string) string {
+	used := map[string]bool{}
+	for _, volume := range podSpec.Volumes {
+		if volume != nil && volume.Name != nil {
+			used[*volume.Name] = true
+		}
+	}
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%s-%d", prefix, i)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+// isQuantity 判断 s 是否为合法的 Kubernetes resource.Quantity 字符串.
+func isQuantity(s string) bool {
+	return quantityPattern.MatchString(s)
+}
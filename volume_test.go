@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+func TestLogVolumeSettingsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings LogVolumeSettings
+		wantErr  bool
+	}{
+		{
+			name:     "disabled settings are not checked",
+			settings: LogVolumeSettings{Type: "nfs"},
+		},
+		{
+			name:     "emptyDir with size limit",
+			settings: LogVolumeSettings{Enabled: true, SizeLimit: "500Mi"},
+		},
+		{
+			name:     "hostPath with prefix",
+			settings: LogVolumeSettings{Enabled: true, Type: "hostPath", HostPathPrefix: "/var/log/pods-files"},
+		},
+		{
+			name:     "unknown type",
+			settings: LogVolumeSettings{Enabled: true, Type: "nfs"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid size limit",
+			settings: LogVolumeSettings{Enabled: true, SizeLimit: "lots"},
+			wantErr:  true,
+		},
+		{
+			name:     "hostPath without prefix",
+			settings: LogVolumeSettings{Enabled: true, Type: "hostPath"},
+			wantErr:  true,
+		},
+		{
+			name:     "hostPath with relative prefix",
+			settings: LogVolumeSettings{Enabled: true, Type: "hostPath", HostPathPrefix: "logs"},
+			wantErr:  true,
+		},
+		{
+			name: "hostPath with size limit",
+			settings: LogVolumeSettings{
+				Enabled: true, Type: "hostPath", HostPathPrefix: "/var/log/pods-files", SizeLimit: "1Gi",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "invalid name prefix",
+			settings: LogVolumeSettings{Enabled: true, NamePrefix: "Log_Volume"},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.Valid()
+			if test.wantErr && err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnsureLogVolumesEmptyDir(t *testing.T) {
+	container := &corev1.Container{
+		Name: stringPtr("app"),
+		VolumeMounts: []*corev1.VolumeMount{
+			{Name: stringPtr("data"), MountPath: stringPtr("/data")},
+		},
+	}
+	podSpec := &corev1.PodSpec{
+		Containers: []*corev1.Container{container},
+		Volumes:    []*corev1.Volume{{Name: stringPtr("data")}, {Name: stringPtr("env-log-0")}},
+	}
+	settings := &LogVolumeSettings{Enabled: true, SizeLimit: "500Mi"}
+
+	mutated := ensureLogVolumes(podSpec, container, []string{
+		"/var/log/app/info.log",
+		"/var/log/app/error.log",
+		"/data/logs/app.log",
+		"/var/log/other/*/access.log",
+		"relative/app.log",
+	}, settings)
+	if !mutated {
+		t.Fatalf("Expected log volumes to be injected")
+	}
+
+	expectedMounts := map[string]string{
+		"env-log-1": "/var/log/app",
+		"env-log-2": "/var/log/other",
+	}
+	if len(podSpec.Volumes) != 4 {
+		t.Fatalf("Expected 4 volumes, got %d", len(podSpec.Volumes))
+	}
+	for _, volume := range podSpec.Volumes[2:] {
+		if _, ok := expectedMounts[*volume.Name]; !ok {
+			t.Errorf("Unexpected volume %s", *volume.Name)
+		}
+		if volume.EmptyDir == nil || volume.EmptyDir.SizeLimit == nil || *volume.EmptyDir.SizeLimit != "500Mi" {
+			t.Errorf("Expected volume %s to be an emptyDir limited to 500Mi", *volume.Name)
+		}
+	}
+	if len(container.VolumeMounts) != 3 {
+		t.Fatalf("Expected 3 volume mounts, got %d", len(container.VolumeMounts))
+	}
+	for _, mount := range container.VolumeMounts[1:] {
+		if expectedMounts[*mount.Name] != *mount.MountPath {
+			t.Errorf("Expected %s to be mounted at %s, got %s",
+				*mount.Name, expectedMounts[*mount.Name], *mount.MountPath)
+		}
+	}
+
+	// 再次执行时所有目录均已挂载，不应重复注入
+	if ensureLogVolumes(podSpec, container, []string{"/var/log/app/info.log"}, settings) {
+		t.Errorf("Expected no mutation when log directories are already mounted")
+	}
+}
+
+func TestEnsureLogVolumesHostPath(t *testing.T) {
+	container := &corev1.Container{Name: stringPtr("app")}
+	podSpec := &corev1.PodSpec{Containers: []*corev1.Container{container}}
+	settings := &LogVolumeSettings{
+		Enabled:        true,
+		Type:           "hostPath",
+		HostPathPrefix: "/var/log/pods-files",
+		NamePrefix:     "app-logs",
+	}
+
+	ensureLogVolumes(podSpec, container, []string{"/var/log/app/info.log"}, settings)
+
+	if len(podSpec.Volumes) != 1 {
+		t.Fatalf("Expected 1 volume, got %d", len(podSpec.Volumes))
+	}
+	volume := podSpec.Volumes[0]
+	if *volume.Name != "app-logs-0" {
+		t.Errorf("Expected volume name app-logs-0, got %s", *volume.Name)
+	}
+	if volume.HostPath == nil || *volume.HostPath.Path != "/var/log/pods-files/var/log/app" {
+		t.Errorf("Expected hostPath /var/log/pods-files/var/log/app, got %+v", volume.HostPath)
+	}
+	// 同一节点上的不同 Pod 各自写入 <命名空间>/<Pod 名称> 子目录
+	if mount := container.VolumeMounts[0]; mount.SubPathExpr != hostPathSubPathExpr {
+		t.Errorf("Expected subPathExpr %q, got %q", hostPathSubPathExpr, mount.SubPathExpr)
+	}
+	fieldPaths := map[string]string{}
+	for _, env := range container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.FieldRef != nil {
+			fieldPaths[*env.Name] = *env.ValueFrom.FieldRef.FieldPath
+		}
+	}
+	expectedFieldPaths := map[string]string{podNamespaceEnv: "metadata.namespace", podNameEnv: "metadata.name"}
+	if !reflect.DeepEqual(fieldPaths, expectedFieldPaths) {
+		t.Errorf("Expected downward API env %v, got %v", expectedFieldPaths, fieldPaths)
+	}
+
+	// 第二个目录复用已有的环境变量
+	ensureLogVolumes(podSpec, container, []string{"/var/log/other/info.log"}, settings)
+	if len(container.Env) != 2 {
+		t.Errorf("Expected the downward API env to be added once, got %d variables", len(container.Env))
+	}
+}
+
+func TestEnsureLogVolumesSharesDirectories(t *testing.T) {
+	first := &corev1.Container{Name: stringPtr("a")}
+	second := &corev1.Container{Name: stringPtr("b")}
+	podSpec := &corev1.PodSpec{Containers: []*corev1.Container{first, second}}
+	settings := &LogVolumeSettings{Enabled: true}
+
+	ensureLogVolumes(podSpec, first, []string{"/var/log/a.log"}, settings)
+	ensureLogVolumes(podSpec, second, []string{"/var/log/b.log"}, settings)
+
+	if len(podSpec.Volumes) != 1 {
+		t.Fatalf("Expected the containers to share 1 volume, got %d", len(podSpec.Volumes))
+	}
+	if len(second.VolumeMounts) != 1 || *second.VolumeMounts[0].Name != "env-log-0" ||
+		*second.VolumeMounts[0].MountPath != "/var/log" {
+		t.Errorf("Expected env-log-0 to be mounted at /var/log, got %+v", second.VolumeMounts)
+	}
+}